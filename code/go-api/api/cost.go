@@ -0,0 +1,139 @@
+package api
+
+import (
+	"airdock/store"
+	"airdock/store/business"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+)
+
+func handleGetLaborBudget(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		lb, err := bStore.GetLaborBudget(ctx.Request().Context())
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, lb)
+	}
+}
+
+func handleSetLaborBudget(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Weekly  int64 `json:"weekly" validate:"gte=0"`
+		Monthly int64 `json:"monthly" validate:"gte=0"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		lb := business.LaborBudget(req)
+		err = bStore.SetLaborBudget(ctx.Request().Context(), lb)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, lb)
+	}
+}
+
+// handleGetScheduleCost returns the labor cost of the stored schedule of a
+// week.
+func handleGetScheduleCost(bStore *business.BusinessStore, eStore *store.EmployeeStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Week string `param:"week" validate:"required,datetime=2006-01-02"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		week, err := time.ParseInLocation(time.DateOnly, req.Week, bStore.Location())
+		if err != nil {
+			return ctx.String(http.StatusBadRequest, "invalid date format, expected format is YYYY-MM-DD")
+		}
+
+		ws, err := bStore.GetScheduleForWeek(ctx.Request().Context(), week)
+		if errors.Is(err, business.ErrConfigNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		employees, err := eStore.All(ctx.Request().Context())
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		sc, err := bStore.ScheduleCost(ctx.Request().Context(), week, ws, employees)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, sc)
+	}
+}
+
+// handleEstimateScheduleCost returns the labor cost of a schedule that has not
+// been stored yet, e.g. a generated one.
+func handleEstimateScheduleCost(bStore *business.BusinessStore, eStore *store.EmployeeStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Week     string                `param:"week" validate:"required,datetime=2006-01-02"`
+		Schedule business.WeekSchedule `json:"schedule" validate:"required"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		week, err := time.ParseInLocation(time.DateOnly, req.Week, bStore.Location())
+		if err != nil {
+			return ctx.String(http.StatusBadRequest, "invalid date format, expected format is YYYY-MM-DD")
+		}
+
+		employees, err := eStore.All(ctx.Request().Context())
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		sc, err := bStore.ScheduleCost(ctx.Request().Context(), week, req.Schedule, employees)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, sc)
+	}
+}
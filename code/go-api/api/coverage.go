@@ -0,0 +1,70 @@
+package api
+
+import (
+	"airdock/store"
+	"airdock/store/business"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+)
+
+// maxCoverageDays limits the range of a coverage report, as the availability
+// of every employee is loaded for every week of it.
+const maxCoverageDays = 92
+
+// handleGetCoverage reports, per day and shift, how many employees the
+// timetable requires, how many are scheduled and how many are available.
+func handleGetCoverage(bStore *business.BusinessStore, eStore *store.EmployeeStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		From string `query:"from" validate:"required,datetime=2006-01-02"`
+		To   string `query:"to" validate:"required,datetime=2006-01-02"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		from, err := time.ParseInLocation(time.DateOnly, req.From, bStore.Location())
+		if err != nil {
+			return ctx.String(http.StatusBadRequest, "invalid date format, expected format is YYYY-MM-DD")
+		}
+		to, err := time.ParseInLocation(time.DateOnly, req.To, bStore.Location())
+		if err != nil {
+			return ctx.String(http.StatusBadRequest, "invalid date format, expected format is YYYY-MM-DD")
+		}
+		if to.Before(from) {
+			return ctx.String(http.StatusBadRequest, "to must not be before from")
+		}
+		if to.After(from.AddDate(0, 0, maxCoverageDays)) {
+			return ctx.String(http.StatusBadRequest, "range must not be longer than 92 days")
+		}
+
+		employees, err := eStore.All(ctx.Request().Context())
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+		ava, err := eStore.GetAllEmployeesAvailabilityForWeeks(ctx.Request().Context(), from, to)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		report, err := bStore.Coverage(ctx.Request().Context(), from, to, employees, ava)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, report)
+	}
+}
@@ -2,6 +2,9 @@ package api
 
 import (
 	"airdock/store"
+	"airdock/store/business"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
@@ -11,30 +14,49 @@ import (
 )
 
 type EmployeeDTO struct {
-	Name             string `json:"name"`
-	Email            string `json:"email"`
-	Address          string `json:"address"`
-	DateOfBirth      string `json:"dateOfBirth"`
-	EmergencyContact string `json:"emergencyContact"`
+	Name             string   `json:"name"`
+	Email            string   `json:"email"`
+	Address          string   `json:"address"`
+	DateOfBirth      string   `json:"dateOfBirth"`
+	EmergencyContact string   `json:"emergencyContact"`
+	Skills           []string `json:"skills"`
+	HireDate         string   `json:"hireDate,omitempty"`
+	HourlyWage       int64    `json:"hourlyWage"`
+	Agreement        string   `json:"agreement,omitempty"`
 }
 
 func mapEmployeeToDTO(e store.Employee) EmployeeDTO {
-	return EmployeeDTO{
+	skills := e.Skills
+	if skills == nil {
+		skills = []string{}
+	}
+	dto := EmployeeDTO{
 		Name:             e.Name,
 		Email:            e.Email,
 		Address:          e.Address,
 		DateOfBirth:      time.Unix(e.DateOfBirth, 0).Format("2006-01-02"),
 		EmergencyContact: strconv.FormatInt(e.EmergencyContact, 10),
+		Skills:           skills,
+		HourlyWage:       e.HourlyWage,
+		Agreement:        e.Agreement,
+	}
+	if e.HireDate != 0 {
+		dto.HireDate = time.Unix(e.HireDate, 0).UTC().Format("2006-01-02")
 	}
+	return dto
 }
 
 func handleCreateEmployee(eStore *store.EmployeeStore, logger *log.Logger) echo.HandlerFunc {
 	type request struct {
-		Name             string `json:"name" validate:"required"`
-		Email            string `json:"email" validate:"required,email"`
-		Address          string `json:"address" validate:"required"`
-		DateOfBirth      string `json:"dateOfBirth" validate:"required"`
-		EmergencyContact string `json:"emergencyContact" validate:"required,numeric"`
+		Name             string   `json:"name" validate:"required"`
+		Email            string   `json:"email" validate:"required,email"`
+		Address          string   `json:"address" validate:"required"`
+		DateOfBirth      string   `json:"dateOfBirth" validate:"required"`
+		EmergencyContact string   `json:"emergencyContact" validate:"required,numeric"`
+		Skills           []string `json:"skills" validate:"omitempty,dive,required"`
+		HireDate         string   `json:"hireDate" validate:"omitempty,datetime=2006-01-02"`
+		HourlyWage       int64    `json:"hourlyWage" validate:"gte=0"`
+		Agreement        string   `json:"agreement"`
 	}
 	return func(ctx echo.Context) error {
 		var req request
@@ -64,6 +86,16 @@ func handleCreateEmployee(eStore *store.EmployeeStore, logger *log.Logger) echo.
 			Address:          req.Address,
 			DateOfBirth:      dob.Unix(),
 			EmergencyContact: int64(ec),
+			Skills:           req.Skills,
+			HourlyWage:       req.HourlyWage,
+			Agreement:        req.Agreement,
+		}
+		if req.HireDate != "" {
+			hired, err := time.Parse("2006-01-02", req.HireDate)
+			if err != nil {
+				return ctx.String(http.StatusBadRequest, "invalid date format, expected format is YYYY-MM-DD")
+			}
+			employee.HireDate = hired.Unix()
 		}
 
 		err = eStore.Create(ctx.Request().Context(), employee)
@@ -128,6 +160,106 @@ func handleDeleteEmployee(eStore *store.EmployeeStore, logger *log.Logger) echo.
 	}
 }
 
+// respondUpdatedEmployee responds with the employee stored at email after an
+// update of it that returned err.
+func respondUpdatedEmployee(ctx echo.Context, eStore *store.EmployeeStore, logger *log.Logger, email string, err error) error {
+	if errors.Is(err, store.ErrEmployeeNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound)
+	}
+	if err != nil {
+		logger.Warn(err)
+		return echo.NewHTTPError(http.StatusInternalServerError)
+	}
+
+	employee, err := eStore.Get(ctx.Request().Context(), email)
+	if err != nil {
+		logger.Warn(err)
+		return echo.NewHTTPError(http.StatusInternalServerError)
+	}
+
+	return ctx.JSON(http.StatusOK, mapEmployeeToDTO(employee))
+}
+
+func handleSetEmployeeSkills(eStore *store.EmployeeStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Email  string   `param:"email" validate:"required,email"`
+		Skills []string `json:"skills" validate:"required,dive,required"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		err = eStore.SetSkills(ctx.Request().Context(), req.Email, req.Skills)
+		return respondUpdatedEmployee(ctx, eStore, logger, req.Email, err)
+	}
+}
+
+// handleSetEmployeeWage sets the hourly wage of an employee, in minor units of
+// the business currency.
+func handleSetEmployeeWage(eStore *store.EmployeeStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Email      string `param:"email" validate:"required,email"`
+		HourlyWage int64  `json:"hourlyWage" validate:"gte=0"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		err = eStore.SetHourlyWage(ctx.Request().Context(), req.Email, req.HourlyWage)
+		return respondUpdatedEmployee(ctx, eStore, logger, req.Email, err)
+	}
+}
+
+// handleSetEmployeeAgreement sets the collective agreement whose pay
+// supplements apply to an employee. An empty agreement means the default one,
+// any other must be one of the agreements of the supplement settings.
+func handleSetEmployeeAgreement(bStore *business.BusinessStore, eStore *store.EmployeeStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Email     string `param:"email" validate:"required,email"`
+		Agreement string `json:"agreement"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		ss, err := bStore.GetSupplementSettings(ctx.Request().Context())
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError)
+		}
+		if !ss.HasAgreement(req.Agreement) {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("unknown agreement %q", req.Agreement))
+		}
+
+		err = eStore.SetAgreement(ctx.Request().Context(), req.Email, req.Agreement)
+		return respondUpdatedEmployee(ctx, eStore, logger, req.Email, err)
+	}
+}
+
 func handleGetAllEmployees(eStore *store.EmployeeStore, logger *log.Logger) echo.HandlerFunc {
 	return func(ctx echo.Context) error {
 		employees, err := eStore.All(ctx.Request().Context())
@@ -148,6 +280,8 @@ func handleGetAllEmployees(eStore *store.EmployeeStore, logger *log.Logger) echo
 func handleGetEmployeeAvailability(eStore *store.EmployeeStore, logger *log.Logger) echo.HandlerFunc {
 	type request struct {
 		Email string `param:"email" validate:"required,email"`
+		From  string `query:"from" validate:"omitempty,datetime=2006-01-02"`
+		To    string `query:"to" validate:"omitempty,datetime=2006-01-02"`
 	}
 	return func(ctx echo.Context) error {
 		var req request
@@ -161,13 +295,28 @@ func handleGetEmployeeAvailability(eStore *store.EmployeeStore, logger *log.Logg
 			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 		}
 
+		from := time.Now().In(eStore.Location())
+		if req.From != "" {
+			from, err = time.ParseInLocation("2006-01-02", req.From, eStore.Location())
+			if err != nil {
+				return ctx.String(http.StatusBadRequest, "invalid date format, expected format is YYYY-MM-DD")
+			}
+		}
+		to := from.AddDate(1, 0, 0)
+		if req.To != "" {
+			to, err = time.ParseInLocation("2006-01-02", req.To, eStore.Location())
+			if err != nil {
+				return ctx.String(http.StatusBadRequest, "invalid date format, expected format is YYYY-MM-DD")
+			}
+		}
+
 		availability, err := eStore.Availability(ctx.Request().Context(), req.Email)
 		if err != nil {
 			logger.Warn(err)
 			return echo.NewHTTPError(http.StatusNotFound)
 		}
 
-		return ctx.JSON(http.StatusOK, availability)
+		return ctx.JSON(http.StatusOK, availability.Materialize(from, to))
 	}
 }
 
@@ -187,7 +336,7 @@ func handleGetAllEmployeeAvailabilityForWeek(eStore *store.EmployeeStore, logger
 			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 		}
 
-		week, err := time.Parse("2006-01-02", req.Week)
+		week, err := time.ParseInLocation("2006-01-02", req.Week, eStore.Location())
 		if err != nil {
 			return ctx.String(http.StatusBadRequest, "invalid date format, expected format is YYYY-MM-DD")
 		}
@@ -202,14 +351,106 @@ func handleGetAllEmployeeAvailabilityForWeek(eStore *store.EmployeeStore, logger
 	}
 }
 
-func handleSetAvaMeep(eStore *store.EmployeeStore, logger *log.Logger) echo.HandlerFunc {
-	type ava struct {
-		Availability string `json:"availability" validate:"required"`
+var weekdaysByName = map[string]time.Weekday{
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+	"sunday":    time.Sunday,
+}
+
+// availabilityWindow is a part of a day with a level of availability. A "to"
+// that is not after "from" means the window lasts until midnight.
+type availabilityWindow struct {
+	From  string `json:"from" validate:"required,datetime=15:04"`
+	To    string `json:"to" validate:"required,datetime=15:04"`
+	Level string `json:"level" validate:"required,oneof=preferred available unavailable"`
+}
+
+// mapWindows maps the windows of a day. Windows are only allowed on available
+// and partial days, and partial days need either windows or "from" and "to".
+func mapWindows(availability string, from string, dtos []availabilityWindow) ([]store.AvailabilityWindow, error) {
+	if len(dtos) > 0 && availability == store.AvailabilityUnavailable {
+		return nil, errors.New("windows are only allowed on available and partial days")
+	}
+	if len(dtos) == 0 && availability == store.AvailabilityPartial && from == "" {
+		return nil, errors.New("partial days need windows or from and to")
+	}
+
+	windows := make([]store.AvailabilityWindow, 0, len(dtos))
+	for _, w := range dtos {
+		from, err := store.ParseClock(w.From)
+		if err != nil {
+			return nil, err
+		}
+		to, err := store.ParseClock(w.To)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, store.AvailabilityWindow{
+			From:  from,
+			To:    to,
+			Level: w.Level,
+		})
+	}
+	if len(windows) == 0 {
+		return nil, nil
+	}
+	return windows, nil
+}
+
+type dayAvailability struct {
+	Availability string               `json:"availability" validate:"required,oneof=available unavailable partial"`
+	From         string               `json:"from" validate:"required_with=To,omitempty,datetime=15:04"`
+	To           string               `json:"to" validate:"required_with=From,omitempty,datetime=15:04"`
+	Windows      []availabilityWindow `json:"windows" validate:"omitempty,dive"`
+}
+
+func (da dayAvailability) mapToStore(date time.Time) (store.DayAvilability, error) {
+	windows, err := mapWindows(da.Availability, da.From, da.Windows)
+	if err != nil {
+		return store.DayAvilability{}, err
+	}
+
+	day := store.DayAvilability{
+		Date:         date,
+		Availability: da.Availability,
+		Windows:      windows,
+	}
+	if da.Availability != store.AvailabilityPartial || da.From == "" {
+		return day, nil
+	}
+
+	fromClock, err := store.ParseClock(da.From)
+	if err != nil {
+		return store.DayAvilability{}, err
+	}
+	toClock, err := store.ParseClock(da.To)
+	if err != nil {
+		return store.DayAvilability{}, err
+	}
+	if toClock <= fromClock {
+		return store.DayAvilability{}, errors.New("to must be after from")
 	}
+
+	from := fromClock.On(date)
+	to := toClock.On(date)
+	day.From = &from
+	day.To = &to
+	return day, nil
+}
+
+// handleSetAvaMeep updates the availability of single days in a week. Days
+// are given by weekday name in "days", or "availability" may be used to set
+// every day of the week at once.
+func handleSetAvaMeep(eStore *store.EmployeeStore, logger *log.Logger) echo.HandlerFunc {
 	type request struct {
-		Email string `param:"email" validate:"required,email"`
-		Week  string `param:"week" validate:"required,datetime=2006-01-02"`
-		Ava   ava    `json:"ava" validate:"required"`
+		Email        string                     `param:"email" validate:"required,email"`
+		Week         string                     `param:"week" validate:"required,datetime=2006-01-02"`
+		Availability *dayAvailability           `json:"availability" validate:"required_without=Days,omitempty"`
+		Days         map[string]dayAvailability `json:"days" validate:"required_without=Availability,omitempty,dive"`
 	}
 
 	return func(ctx echo.Context) error {
@@ -224,13 +465,207 @@ func handleSetAvaMeep(eStore *store.EmployeeStore, logger *log.Logger) echo.Hand
 			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 		}
 
-		week, err := time.Parse("2006-01-02", req.Week)
+		week, err := time.ParseInLocation("2006-01-02", req.Week, eStore.Location())
 		if err != nil {
 			return ctx.String(http.StatusBadRequest, "invalid date format, expected format is YYYY-MM-DD")
 		}
 
+		week = store.StartOfWeek(week)
+
+		dtoDays := req.Days
+		if len(dtoDays) == 0 {
+			dtoDays = make(map[string]dayAvailability, len(weekdaysByName))
+			for name := range weekdaysByName {
+				dtoDays[name] = *req.Availability
+			}
+		}
+
+		days := make(map[time.Weekday]store.DayAvilability, len(dtoDays))
+		for name, dto := range dtoDays {
+			weekday, ok := weekdaysByName[name]
+			if !ok {
+				return ctx.String(http.StatusBadRequest, fmt.Sprintf("unknown weekday %q", name))
+			}
+
+			offset := (int(weekday) + 6) % 7
+			day, err := dto.mapToStore(week.AddDate(0, 0, offset))
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+			days[weekday] = day
+		}
+
 		logger.Printf("setting availability for %v on %v", req.Email, week.Format("2006-01-02"))
 
-		return ctx.NoContent(http.StatusNoContent)
+		wa, err := eStore.SetAvailabilityForWeek(ctx.Request().Context(), req.Email, week, days)
+		if errors.Is(err, store.ErrEmployeeNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError)
+		}
+
+		return ctx.JSON(http.StatusOK, wa)
+	}
+}
+
+type dayPattern struct {
+	Availability string               `json:"availability" validate:"required,oneof=available unavailable partial"`
+	From         string               `json:"from" validate:"required_with=To,omitempty,datetime=15:04"`
+	To           string               `json:"to" validate:"required_with=From,omitempty,datetime=15:04"`
+	Windows      []availabilityWindow `json:"windows" validate:"omitempty,dive"`
+}
+
+func (dp dayPattern) mapToStore() (store.DayPattern, error) {
+	windows, err := mapWindows(dp.Availability, dp.From, dp.Windows)
+	if err != nil {
+		return store.DayPattern{}, err
+	}
+	if dp.Availability != store.AvailabilityPartial || dp.From == "" {
+		return store.DayPattern{Availability: dp.Availability, Windows: windows}, nil
+	}
+
+	from, err := store.ParseClock(dp.From)
+	if err != nil {
+		return store.DayPattern{}, err
+	}
+	to, err := store.ParseClock(dp.To)
+	if err != nil {
+		return store.DayPattern{}, err
+	}
+	if to <= from {
+		return store.DayPattern{}, errors.New("to must be after from")
+	}
+
+	return store.DayPattern{
+		Availability: dp.Availability,
+		From:         from,
+		To:           to,
+		Windows:      windows,
+	}, nil
+}
+
+type availabilityPattern struct {
+	EffectiveFrom string     `json:"effectiveFrom" validate:"required,datetime=2006-01-02"`
+	EffectiveTo   string     `json:"effectiveTo" validate:"omitempty,datetime=2006-01-02"`
+	Monday        dayPattern `json:"monday" validate:"required"`
+	Tuesday       dayPattern `json:"tuesday" validate:"required"`
+	Wednesday     dayPattern `json:"wednesday" validate:"required"`
+	Thursday      dayPattern `json:"thursday" validate:"required"`
+	Friday        dayPattern `json:"friday" validate:"required"`
+	Saturday      dayPattern `json:"saturday" validate:"required"`
+	Sunday        dayPattern `json:"sunday" validate:"required"`
+}
+
+func (p availabilityPattern) mapToStore(loc *time.Location) (store.AvailabilityPattern, error) {
+	from, err := time.ParseInLocation("2006-01-02", p.EffectiveFrom, loc)
+	if err != nil {
+		return store.AvailabilityPattern{}, err
+	}
+
+	var to *time.Time
+	if p.EffectiveTo != "" {
+		t, err := time.ParseInLocation("2006-01-02", p.EffectiveTo, loc)
+		if err != nil {
+			return store.AvailabilityPattern{}, err
+		}
+		if t.Before(from) {
+			return store.AvailabilityPattern{}, errors.New("effectiveTo must not be before effectiveFrom")
+		}
+		to = &t
+	}
+
+	dtoDays := []dayPattern{p.Monday, p.Tuesday, p.Wednesday, p.Thursday, p.Friday, p.Saturday, p.Sunday}
+	days := make([]store.DayPattern, 0, len(dtoDays))
+	for _, dp := range dtoDays {
+		day, err := dp.mapToStore()
+		if err != nil {
+			return store.AvailabilityPattern{}, err
+		}
+		days = append(days, day)
+	}
+
+	return store.AvailabilityPattern{
+		EffectiveFrom: from,
+		EffectiveTo:   to,
+		Monday:        days[0],
+		Tuesday:       days[1],
+		Wednesday:     days[2],
+		Thursday:      days[3],
+		Friday:        days[4],
+		Saturday:      days[5],
+		Sunday:        days[6],
+	}, nil
+}
+
+func handleGetAvailabilityPatterns(eStore *store.EmployeeStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Email string `param:"email" validate:"required,email"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		availability, err := eStore.Availability(ctx.Request().Context(), req.Email)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+
+		patterns := availability.Patterns
+		if patterns == nil {
+			patterns = []store.AvailabilityPattern{}
+		}
+		return ctx.JSON(http.StatusOK, patterns)
+	}
+}
+
+// handleSetAvailabilityPatterns replaces all availability patterns of an
+// employee. Weeks set explicitly through handleSetAvaMeep are kept.
+func handleSetAvailabilityPatterns(eStore *store.EmployeeStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Email    string                `param:"email" validate:"required,email"`
+		Patterns []availabilityPattern `json:"patterns" validate:"required,dive"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		patterns := make([]store.AvailabilityPattern, 0, len(req.Patterns))
+		for _, p := range req.Patterns {
+			pattern, err := p.mapToStore(eStore.Location())
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+			patterns = append(patterns, pattern)
+		}
+
+		err = eStore.SetAvailabilityPatterns(ctx.Request().Context(), req.Email, patterns)
+		if errors.Is(err, store.ErrEmployeeNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError)
+		}
+
+		return ctx.JSON(http.StatusOK, patterns)
 	}
 }
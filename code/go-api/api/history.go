@@ -0,0 +1,173 @@
+package api
+
+import (
+	"airdock/store/business"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+)
+
+func handleGetScheduleVersions(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Week string `param:"week" validate:"required,datetime=2006-01-02"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		week, err := time.ParseInLocation(time.DateOnly, req.Week, bStore.Location())
+		if err != nil {
+			return ctx.String(http.StatusBadRequest, "invalid date format, expected format is YYYY-MM-DD")
+		}
+
+		versions, err := bStore.ScheduleVersions(ctx.Request().Context(), week)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, versions)
+	}
+}
+
+func handleGetScheduleVersion(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Week    string `param:"week" validate:"required,datetime=2006-01-02"`
+		Version int    `param:"version" validate:"required,gt=0"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		week, err := time.ParseInLocation(time.DateOnly, req.Week, bStore.Location())
+		if err != nil {
+			return ctx.String(http.StatusBadRequest, "invalid date format, expected format is YYYY-MM-DD")
+		}
+
+		sv, err := bStore.GetScheduleVersion(ctx.Request().Context(), week, req.Version)
+		if errors.Is(err, business.ErrVersionNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, sv)
+	}
+}
+
+// handleDiffScheduleVersions lists what changed from version "from" to
+// version "to" of a week's schedule. Without "to" the current schedule is
+// compared.
+func handleDiffScheduleVersions(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Week string `param:"week" validate:"required,datetime=2006-01-02"`
+		From int    `query:"from" validate:"required,gt=0"`
+		To   int    `query:"to" validate:"omitempty,gt=0"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		week, err := time.ParseInLocation(time.DateOnly, req.Week, bStore.Location())
+		if err != nil {
+			return ctx.String(http.StatusBadRequest, "invalid date format, expected format is YYYY-MM-DD")
+		}
+
+		from, err := bStore.GetScheduleVersion(ctx.Request().Context(), week, req.From)
+		if errors.Is(err, business.ErrVersionNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "version not found")
+		}
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		var to business.WeekSchedule
+		if req.To != 0 {
+			sv, err := bStore.GetScheduleVersion(ctx.Request().Context(), week, req.To)
+			if errors.Is(err, business.ErrVersionNotFound) {
+				return echo.NewHTTPError(http.StatusNotFound, "version not found")
+			}
+			if err != nil {
+				logger.Warn(err)
+				return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+			}
+			to = sv.Schedule
+		} else {
+			to, err = bStore.GetScheduleForWeek(ctx.Request().Context(), week)
+			if err != nil && !errors.Is(err, business.ErrConfigNotFound) {
+				logger.Warn(err)
+				return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+			}
+		}
+
+		return ctx.JSON(http.StatusOK, business.DiffSchedules(week, from.Schedule, to))
+	}
+}
+
+func handleRestoreScheduleVersion(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Week    string `param:"week" validate:"required,datetime=2006-01-02"`
+		Version int    `param:"version" validate:"required,gt=0"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		week, err := time.ParseInLocation(time.DateOnly, req.Week, bStore.Location())
+		if err != nil {
+			return ctx.String(http.StatusBadRequest, "invalid date format, expected format is YYYY-MM-DD")
+		}
+
+		sv, err := bStore.RestoreScheduleVersion(ctx.Request().Context(), week, req.Version, actor(ctx))
+		if errors.Is(err, business.ErrVersionNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+		if errors.Is(err, business.ErrScheduleLocked) {
+			return ctx.String(http.StatusConflict, "the schedule of this week is locked")
+		}
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusCreated, sv)
+	}
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"airdock/store/business"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+)
+
+// handleGetHolidays lists the holidays between from and to of the given
+// country, or of the configured country if none is given.
+func handleGetHolidays(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		From    string `query:"from" validate:"required,datetime=2006-01-02"`
+		To      string `query:"to" validate:"required,datetime=2006-01-02"`
+		Country string `query:"country" validate:"omitempty,oneof=SE NO DK FI"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		from, err := time.ParseInLocation(time.DateOnly, req.From, bStore.Location())
+		if err != nil {
+			return ctx.String(http.StatusBadRequest, "invalid date format, expected format is YYYY-MM-DD")
+		}
+		to, err := time.ParseInLocation(time.DateOnly, req.To, bStore.Location())
+		if err != nil {
+			return ctx.String(http.StatusBadRequest, "invalid date format, expected format is YYYY-MM-DD")
+		}
+
+		var holidays []business.Holiday
+		if req.Country != "" {
+			holidays, err = business.Holidays(req.Country, from, to)
+		} else {
+			holidays, err = bStore.GetHolidays(ctx.Request().Context(), from, to)
+		}
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, holidays)
+	}
+}
+
+func handleGetHolidaySettings(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		hs, err := bStore.GetHolidaySettings(ctx.Request().Context())
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, hs)
+	}
+}
+
+// handleSetHolidaySettings sets the country whose holidays apply and what
+// happens to the timetable on them, either for all holidays with "default" or
+// for single holidays by ID with "rules".
+func handleSetHolidaySettings(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Country string            `json:"country" validate:"omitempty,oneof=SE NO DK FI"`
+		Default string            `json:"default" validate:"required,oneof=open closed sunday"`
+		Rules   map[string]string `json:"rules" validate:"omitempty,dive,keys,required,endkeys,oneof=open closed sunday"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		hs := business.HolidaySettings{
+			Country: req.Country,
+			Default: req.Default,
+			Rules:   req.Rules,
+		}
+		if hs.Rules == nil {
+			hs.Rules = map[string]string{}
+		}
+
+		err = bStore.SetHolidaySettings(ctx.Request().Context(), hs)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, hs)
+	}
+}
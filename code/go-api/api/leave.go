@@ -0,0 +1,245 @@
+package api
+
+import (
+	"airdock/store"
+	"airdock/store/business"
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+)
+
+// leaveResponse is a leave request along with the shifts of published
+// schedules it conflicts with.
+type leaveResponse struct {
+	store.LeaveRequest
+	Conflicts []business.LeaveConflict `json:"conflicts"`
+}
+
+func withConflicts(ctx context.Context, bStore *business.BusinessStore, lr store.LeaveRequest) (leaveResponse, error) {
+	res := leaveResponse{
+		LeaveRequest: lr,
+		Conflicts:    []business.LeaveConflict{},
+	}
+	if lr.Status != store.LeavePending && lr.Status != store.LeaveApproved {
+		return res, nil
+	}
+
+	conflicts, err := bStore.LeaveConflicts(ctx, lr)
+	if err != nil {
+		return leaveResponse{}, err
+	}
+	res.Conflicts = conflicts
+	return res, nil
+}
+
+func handleGetLeaveRequests(eStore *store.EmployeeStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Employee string `query:"employee" validate:"omitempty,email"`
+		Status   string `query:"status" validate:"omitempty,oneof=pending approved rejected cancelled"`
+		From     string `query:"from" validate:"omitempty,datetime=2006-01-02"`
+		To       string `query:"to" validate:"omitempty,datetime=2006-01-02"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		requests, err := eStore.LeaveRequests(ctx.Request().Context(), store.LeaveFilter{
+			Employee: req.Employee,
+			Status:   req.Status,
+			From:     req.From,
+			To:       req.To,
+		})
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, requests)
+	}
+}
+
+func handleGetLeave(eStore *store.EmployeeStore, bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		ID string `param:"id" validate:"required,uuid"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		lr, err := eStore.GetLeave(ctx.Request().Context(), req.ID)
+		if err != nil {
+			return leaveError(ctx, logger, err)
+		}
+
+		res, err := withConflicts(ctx.Request().Context(), bStore, lr)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+		return ctx.JSON(http.StatusOK, res)
+	}
+}
+
+type leaveHours struct {
+	From string `json:"from" validate:"required,datetime=15:04"`
+	To   string `json:"to" validate:"required,datetime=15:04"`
+}
+
+// handleCreateLeave requests leave for an employee. The shifts of published
+// schedules the employee would miss are returned as conflicts.
+func handleCreateLeave(eStore *store.EmployeeStore, bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Email string      `param:"email" validate:"required,email"`
+		Type  string      `json:"type" validate:"required,oneof=vacation sick parental unpaid other"`
+		From  string      `json:"from" validate:"required,datetime=2006-01-02"`
+		To    string      `json:"to" validate:"required,datetime=2006-01-02"`
+		Hours *leaveHours `json:"hours" validate:"omitempty"`
+		Note  string      `json:"note" validate:"max=500"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		lr := store.LeaveRequest{
+			Employee: req.Email,
+			Type:     req.Type,
+			From:     req.From,
+			To:       req.To,
+			Note:     req.Note,
+		}
+		if req.Hours != nil {
+			from, err := store.ParseClock(req.Hours.From)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+			to, err := store.ParseClock(req.Hours.To)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+			if to <= from {
+				return ctx.String(http.StatusBadRequest, "leave hours must end after they start")
+			}
+			lr.Hours = &store.LeaveHours{
+				From: from,
+				To:   to,
+			}
+		}
+		if lr.To < lr.From {
+			return ctx.String(http.StatusBadRequest, "to must not be before from")
+		}
+
+		lr, err = eStore.CreateLeave(ctx.Request().Context(), lr)
+		if err != nil {
+			return leaveError(ctx, logger, err)
+		}
+
+		res, err := withConflicts(ctx.Request().Context(), bStore, lr)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+		return ctx.JSON(http.StatusCreated, res)
+	}
+}
+
+// handleDecideLeave approves or rejects a leave request. Approved leave makes
+// the employee unavailable, and the shifts of published schedules that now
+// need someone else are returned as conflicts.
+func handleDecideLeave(eStore *store.EmployeeStore, bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		ID      string `param:"id" validate:"required,uuid"`
+		Approve *bool  `json:"approve" validate:"required"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		lr, err := eStore.DecideLeave(ctx.Request().Context(), req.ID, *req.Approve, actor(ctx))
+		if err != nil {
+			return leaveError(ctx, logger, err)
+		}
+
+		res, err := withConflicts(ctx.Request().Context(), bStore, lr)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+		return ctx.JSON(http.StatusOK, res)
+	}
+}
+
+func handleCancelLeave(eStore *store.EmployeeStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		ID string `param:"id" validate:"required,uuid"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		lr, err := eStore.CancelLeave(ctx.Request().Context(), req.ID)
+		if err != nil {
+			return leaveError(ctx, logger, err)
+		}
+
+		return ctx.JSON(http.StatusOK, lr)
+	}
+}
+
+func leaveError(ctx echo.Context, logger *log.Logger, err error) error {
+	switch {
+	case errors.Is(err, store.ErrLeaveNotFound):
+		return echo.NewHTTPError(http.StatusNotFound)
+	case errors.Is(err, store.ErrInvalidLeaveType):
+		return ctx.String(http.StatusBadRequest, err.Error())
+	case errors.Is(err, store.ErrLeaveClosed),
+		errors.Is(err, store.ErrLeaveChanged),
+		errors.Is(err, store.ErrLeaveOverlaps):
+		return ctx.String(http.StatusConflict, err.Error())
+	default:
+		logger.Warn(err)
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"airdock/store/business"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+)
+
+func handleGetScheduleStatus(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Week string `param:"week" validate:"required,datetime=2006-01-02"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		week, err := time.ParseInLocation(time.DateOnly, req.Week, bStore.Location())
+		if err != nil {
+			return ctx.String(http.StatusBadRequest, "invalid date format, expected format is YYYY-MM-DD")
+		}
+
+		ss, err := bStore.GetScheduleStatus(ctx.Request().Context(), week)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, ss)
+	}
+}
+
+// handleTransitionSchedule moves the schedule of a week to the status given
+// by "to", which is either published or locked.
+func handleTransitionSchedule(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Week string `param:"week" validate:"required,datetime=2006-01-02"`
+		To   string `json:"to" validate:"required,oneof=published locked"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		week, err := time.ParseInLocation(time.DateOnly, req.Week, bStore.Location())
+		if err != nil {
+			return ctx.String(http.StatusBadRequest, "invalid date format, expected format is YYYY-MM-DD")
+		}
+
+		var ss business.ScheduleStatus
+		if req.To == business.StatusPublished {
+			ss, err = bStore.PublishSchedule(ctx.Request().Context(), week, actor(ctx))
+		} else {
+			ss, err = bStore.LockSchedule(ctx.Request().Context(), week, actor(ctx))
+		}
+		if errors.Is(err, business.ErrConfigNotFound) {
+			return ctx.String(http.StatusNotFound, "no schedule for this week")
+		}
+		if errors.Is(err, business.ErrInvalidTransition) {
+			return ctx.String(http.StatusConflict, err.Error())
+		}
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, ss)
+	}
+}
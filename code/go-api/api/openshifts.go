@@ -0,0 +1,263 @@
+package api
+
+import (
+	"airdock/store"
+	"airdock/store/business"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+)
+
+func handleGetOpenShifts(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Week string `param:"week" validate:"required,datetime=2006-01-02"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		week, err := time.ParseInLocation(time.DateOnly, req.Week, bStore.Location())
+		if err != nil {
+			return ctx.String(http.StatusBadRequest, "invalid date format, expected format is YYYY-MM-DD")
+		}
+
+		open, err := bStore.OpenShifts(ctx.Request().Context(), week)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, open)
+	}
+}
+
+func handleGetClaimsForWeek(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Week string `param:"week" validate:"required,datetime=2006-01-02"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		week, err := time.ParseInLocation(time.DateOnly, req.Week, bStore.Location())
+		if err != nil {
+			return ctx.String(http.StatusBadRequest, "invalid date format, expected format is YYYY-MM-DD")
+		}
+
+		claims, err := bStore.Claims(ctx.Request().Context(), week)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, claims)
+	}
+}
+
+func handleGetClaim(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		ID string `param:"id" validate:"required,uuid"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		c, err := bStore.GetClaim(ctx.Request().Context(), req.ID)
+		if err != nil {
+			return claimError(ctx, logger, err)
+		}
+
+		return ctx.JSON(http.StatusOK, c)
+	}
+}
+
+// handleClaimShift lets an employee take an open place on a shift, optionally
+// to work "role". If that would break availability, skills or working-time
+// rules the violations are returned with 422 Unprocessable Entity.
+func handleClaimShift(bStore *business.BusinessStore, eStore *store.EmployeeStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Date     string `json:"date" validate:"required,datetime=2006-01-02"`
+		From     string `json:"from" validate:"required,datetime=15:04"`
+		To       string `json:"to" validate:"required,datetime=15:04"`
+		Employee string `json:"employee" validate:"required,email"`
+		Role     string `json:"role"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		date, err := time.ParseInLocation(time.DateOnly, req.Date, bStore.Location())
+		if err != nil {
+			return ctx.String(http.StatusBadRequest, "invalid date format, expected format is YYYY-MM-DD")
+		}
+		from, err := store.ParseClock(req.From)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		to, err := store.ParseClock(req.To)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		employees, ava, err := weekContext(ctx.Request().Context(), eStore, date)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		c, violations, err := bStore.ClaimShift(ctx.Request().Context(), business.ShiftClaim{
+			Date:     req.Date,
+			From:     from,
+			To:       to,
+			Employee: req.Employee,
+			Role:     req.Role,
+		}, employees, ava)
+		if err != nil {
+			return claimError(ctx, logger, err)
+		}
+		if len(violations) > 0 {
+			return ctx.JSON(http.StatusUnprocessableEntity, business.ValidationResult{
+				Valid:      false,
+				Violations: violations,
+			})
+		}
+
+		return ctx.JSON(http.StatusCreated, c)
+	}
+}
+
+func handleDecideClaim(bStore *business.BusinessStore, eStore *store.EmployeeStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		ID      string `param:"id" validate:"required,uuid"`
+		Approve *bool  `json:"approve" validate:"required"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		employees, ava, err := claimContext(ctx.Request().Context(), bStore, eStore, req.ID)
+		if err != nil {
+			return claimError(ctx, logger, err)
+		}
+
+		c, violations, err := bStore.DecideClaim(ctx.Request().Context(), req.ID, *req.Approve, actor(ctx), employees, ava)
+		if err != nil {
+			return claimError(ctx, logger, err)
+		}
+		if len(violations) > 0 {
+			return ctx.JSON(http.StatusUnprocessableEntity, business.ValidationResult{
+				Valid:      false,
+				Violations: violations,
+			})
+		}
+
+		return ctx.JSON(http.StatusOK, c)
+	}
+}
+
+func handleCancelClaim(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		ID string `param:"id" validate:"required,uuid"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		c, err := bStore.CancelClaim(ctx.Request().Context(), req.ID)
+		if err != nil {
+			return claimError(ctx, logger, err)
+		}
+
+		return ctx.JSON(http.StatusOK, c)
+	}
+}
+
+// claimContext loads the employees and their availability for the week of a
+// claim, which are needed to check the claim.
+func claimContext(
+	ctx context.Context,
+	bStore *business.BusinessStore,
+	eStore *store.EmployeeStore,
+	id string,
+) ([]store.Employee, map[string]store.WeekAvailability, error) {
+	c, err := bStore.GetClaim(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	week, err := time.ParseInLocation(time.DateOnly, c.Week, bStore.Location())
+	if err != nil {
+		return nil, nil, err
+	}
+	return weekContext(ctx, eStore, week)
+}
+
+func claimError(ctx echo.Context, logger *log.Logger, err error) error {
+	switch {
+	case errors.Is(err, business.ErrClaimNotFound):
+		return echo.NewHTTPError(http.StatusNotFound)
+	case errors.Is(err, business.ErrClaimClosed),
+		errors.Is(err, business.ErrClaimChanged),
+		errors.Is(err, business.ErrNoOpenPlace),
+		errors.Is(err, business.ErrShiftStarted),
+		errors.Is(err, business.ErrAlreadyAssigned),
+		errors.Is(err, business.ErrAlreadyClaimed),
+		errors.Is(err, business.ErrNotPublished),
+		errors.Is(err, business.ErrScheduleLocked):
+		return ctx.String(http.StatusConflict, err.Error())
+	default:
+		logger.Warn(err)
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"airdock/store/business"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+)
+
+func handleGetTimetableOverrides(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		From string `query:"from" validate:"required,datetime=2006-01-02"`
+		To   string `query:"to" validate:"required,datetime=2006-01-02"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		from, err := time.ParseInLocation(time.DateOnly, req.From, bStore.Location())
+		if err != nil {
+			return ctx.String(http.StatusBadRequest, "invalid date format, expected format is YYYY-MM-DD")
+		}
+		to, err := time.ParseInLocation(time.DateOnly, req.To, bStore.Location())
+		if err != nil {
+			return ctx.String(http.StatusBadRequest, "invalid date format, expected format is YYYY-MM-DD")
+		}
+
+		overrides, err := bStore.TimetableOverrides(ctx.Request().Context(), from, to)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, overrides)
+	}
+}
+
+func handleGetTimetableOverride(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Kind string `param:"kind" validate:"required,oneof=date week"`
+		Date string `param:"date" validate:"required,datetime=2006-01-02"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		date, err := time.ParseInLocation(time.DateOnly, req.Date, bStore.Location())
+		if err != nil {
+			return ctx.String(http.StatusBadRequest, "invalid date format, expected format is YYYY-MM-DD")
+		}
+
+		o, err := bStore.GetTimetableOverride(ctx.Request().Context(), req.Kind, date)
+		if errors.Is(err, business.ErrConfigNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, o)
+	}
+}
+
+// handleSetTimetableOverride replaces the timetable of a single date with
+// "day" or of a whole week with "week". A day without shifts means closed.
+func handleSetTimetableOverride(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Kind string                      `param:"kind" validate:"required,oneof=date week"`
+		Date string                      `param:"date" validate:"required,datetime=2006-01-02"`
+		Note string                      `json:"note"`
+		Day  *day                        `json:"day" validate:"required_if=Kind date,omitempty"`
+		Week *setDefaultTimetableRequest `json:"week" validate:"required_if=Kind week,omitempty"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		date, err := time.ParseInLocation(time.DateOnly, req.Date, bStore.Location())
+		if err != nil {
+			return ctx.String(http.StatusBadRequest, "invalid date format, expected format is YYYY-MM-DD")
+		}
+
+		o := business.TimetableOverride{
+			Kind: req.Kind,
+			Note: req.Note,
+		}
+		if req.Kind == business.OverrideDate {
+			dtt, err := mapDay(*req.Day)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+			o.Day = &dtt
+		} else {
+			wtt, err := req.Week.mapToBusiness()
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+			o.Week = &wtt
+		}
+
+		o, err = bStore.SetTimetableOverride(ctx.Request().Context(), date, o)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, o)
+	}
+}
+
+func handleDeleteTimetableOverride(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Kind string `param:"kind" validate:"required,oneof=date week"`
+		Date string `param:"date" validate:"required,datetime=2006-01-02"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		date, err := time.ParseInLocation(time.DateOnly, req.Date, bStore.Location())
+		if err != nil {
+			return ctx.String(http.StatusBadRequest, "invalid date format, expected format is YYYY-MM-DD")
+		}
+
+		err = bStore.DeleteTimetableOverride(ctx.Request().Context(), req.Kind, date)
+		if errors.Is(err, business.ErrConfigNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.NoContent(http.StatusOK)
+	}
+}
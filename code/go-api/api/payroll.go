@@ -0,0 +1,227 @@
+package api
+
+import (
+	"airdock/store"
+	"airdock/store/business"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+)
+
+func handleGetPayrollSettings(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		ps, err := bStore.GetPayrollSettings(ctx.Request().Context())
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, ps)
+	}
+}
+
+// handleSetPayrollSettings sets the overtime limits and the layout of payroll
+// exports. Layout fields are payroll fields such as "normal_hours", or
+// "rule_hours:" or "rule_pay:" followed by the name of a supplement rule.
+func handleSetPayrollSettings(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type field struct {
+		Field string `json:"field" validate:"required"`
+		Label string `json:"label" validate:"required"`
+		Width int    `json:"width" validate:"gte=1,lte=200"`
+		Align string `json:"align" validate:"oneof=left right"`
+	}
+	type request struct {
+		DailyOvertimeHours  float64 `json:"dailyOvertimeHours" validate:"gte=0,lte=24"`
+		WeeklyOvertimeHours float64 `json:"weeklyOvertimeHours" validate:"gte=0,lte=168"`
+		OvertimePercent     float64 `json:"overtimePercent" validate:"gte=0,lte=1000"`
+		Layout              []field `json:"layout" validate:"dive"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		ps := business.PayrollSettings{
+			DailyOvertimeHours:  req.DailyOvertimeHours,
+			WeeklyOvertimeHours: req.WeeklyOvertimeHours,
+			OvertimePercent:     req.OvertimePercent,
+			Layout:              make([]business.PayrollField, 0, len(req.Layout)),
+		}
+		for _, f := range req.Layout {
+			if !business.IsPayrollField(f.Field) {
+				return ctx.String(http.StatusBadRequest, fmt.Sprintf("unknown payroll field %q", f.Field))
+			}
+			ps.Layout = append(ps.Layout, business.PayrollField(f))
+		}
+
+		err = bStore.SetPayrollSettings(ctx.Request().Context(), ps)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, ps)
+	}
+}
+
+// handleExportPayroll exports the hours and pay of the approved timesheets of
+// every employee from "from" up to and including "to". The "format" is csv,
+// fixed for fixed-width lines or json, all laid out by the payroll settings.
+func handleExportPayroll(bStore *business.BusinessStore, eStore *store.EmployeeStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		From   string `query:"from" validate:"required,datetime=2006-01-02"`
+		To     string `query:"to" validate:"required,datetime=2006-01-02"`
+		Format string `query:"format" validate:"omitempty,oneof=csv fixed json"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		from, to, err := timesheetPeriod(bStore, req.From, req.To)
+		if err != nil {
+			return ctx.String(http.StatusBadRequest, err.Error())
+		}
+
+		employees, err := eStore.All(ctx.Request().Context())
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		p, err := bStore.Payroll(ctx.Request().Context(), from, to, employees)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		filename := fmt.Sprintf("payroll-%s-%s", p.From, p.To)
+		switch req.Format {
+		case "csv":
+			data, err := payrollCSV(p)
+			if err != nil {
+				logger.Warn(err)
+				return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+			}
+			ctx.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename+".csv"))
+			return ctx.Blob(http.StatusOK, "text/csv; charset=utf-8", data)
+		case "fixed":
+			data, err := payrollFixed(p)
+			if err != nil {
+				// the layout of the payroll settings is too narrow
+				return ctx.String(http.StatusUnprocessableEntity, err.Error())
+			}
+			ctx.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename+".txt"))
+			return ctx.Blob(http.StatusOK, echo.MIMETextPlainCharsetUTF8, data)
+		default:
+			return ctx.JSON(http.StatusOK, payrollJSON(p))
+		}
+	}
+}
+
+func payrollValue(v interface{}) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'f', 2, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+func payrollCSV(p business.Payroll) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	header := make([]string, 0, len(p.Layout))
+	for _, f := range p.Layout {
+		header = append(header, f.Label)
+	}
+	err := w.Write(header)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range p.Lines {
+		row := make([]string, 0, len(p.Layout))
+		for _, f := range p.Layout {
+			row = append(row, payrollValue(p.Value(line, f.Field)))
+		}
+		err = w.Write(row)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// payrollFixed lays out every line of p in fixed-width columns, without a
+// header. Text too wide for its column is cut off, but a number is never cut
+// and fails the export instead.
+func payrollFixed(p business.Payroll) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, line := range p.Lines {
+		for _, f := range p.Layout {
+			v := p.Value(line, f.Field)
+			value := []rune(payrollValue(v))
+			if len(value) > f.Width {
+				if _, ok := v.(float64); ok {
+					return nil, fmt.Errorf("%s of %s is %s, wider than %d characters", f.Label, line.Employee, string(value), f.Width)
+				}
+				value = value[:f.Width]
+			}
+			padding := strings.Repeat(" ", f.Width-len(value))
+			if f.Align == business.AlignRight {
+				buf.WriteString(padding + string(value))
+			} else {
+				buf.WriteString(string(value) + padding)
+			}
+		}
+		buf.WriteString("\n")
+	}
+	return buf.Bytes(), nil
+}
+
+type payrollExport struct {
+	From       string                   `json:"from"`
+	To         string                   `json:"to"`
+	Currency   string                   `json:"currency"`
+	Lines      []map[string]interface{} `json:"lines"`
+	Unapproved []string                 `json:"unapproved"`
+}
+
+// payrollJSON keys the values of every line by the labels of the layout.
+func payrollJSON(p business.Payroll) payrollExport {
+	export := payrollExport{
+		From:       p.From,
+		To:         p.To,
+		Currency:   p.Currency,
+		Lines:      make([]map[string]interface{}, 0, len(p.Lines)),
+		Unapproved: p.Unapproved,
+	}
+	for _, line := range p.Lines {
+		values := make(map[string]interface{}, len(p.Layout))
+		for _, f := range p.Layout {
+			values[f.Label] = p.Value(line, f.Field)
+		}
+		export.Lines = append(export.Lines, values)
+	}
+	return export
+}
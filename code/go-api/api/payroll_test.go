@@ -0,0 +1,106 @@
+package api
+
+import (
+	"airdock/store/business"
+	"testing"
+)
+
+func testPayroll(layout []business.PayrollField) business.Payroll {
+	return business.Payroll{
+		From:   "2026-10-12",
+		To:     "2026-10-18",
+		Layout: layout,
+		Lines: []business.PayrollLine{
+			{
+				Employee:        "anna.andersson@x.se",
+				Name:            "Anna Andersson",
+				HourlyWage:      20000,
+				NormalMinutes:   1320,
+				OvertimeMinutes: 300,
+				NormalPay:       440000,
+				OvertimePay:     150000,
+				Supplements:     []business.SupplementPay{{Rule: "evening", Minutes: 180, Amount: 12000}},
+				SupplementPay:   12000,
+				Total:           602000,
+			},
+			{
+				Employee:      "bo@x.se",
+				Name:          "Bo, Jr.",
+				HourlyWage:    18050,
+				NormalMinutes: 90,
+				NormalPay:     27075,
+				Total:         27075,
+			},
+		},
+	}
+}
+
+func TestPayrollCSV(t *testing.T) {
+	p := testPayroll([]business.PayrollField{
+		{Field: business.PayrollEmployee, Label: "email"},
+		{Field: business.PayrollName, Label: "name"},
+		{Field: business.PayrollNormalHours, Label: "hours"},
+		{Field: business.PayrollRuleHoursPrefix + "evening", Label: "evening hours"},
+		{Field: business.PayrollTotal, Label: "total"},
+	})
+
+	got, err := payrollCSV(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "email,name,hours,evening hours,total\n" +
+		"anna.andersson@x.se,Anna Andersson,22.00,3.00,6020.00\n" +
+		"bo@x.se,\"Bo, Jr.\",1.50,0.00,270.75\n"
+	if string(got) != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestPayrollFixed(t *testing.T) {
+	tests := []struct {
+		name    string
+		layout  []business.PayrollField
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "aligned columns",
+			layout: []business.PayrollField{
+				{Field: business.PayrollEmployee, Width: 20, Align: business.AlignLeft},
+				{Field: business.PayrollHourlyWage, Width: 8, Align: business.AlignRight},
+				{Field: business.PayrollTotal, Width: 10, Align: business.AlignRight},
+			},
+			want: "anna.andersson@x.se   200.00   6020.00\n" +
+				"bo@x.se               180.50    270.75\n",
+		},
+		{
+			name: "text is cut off",
+			layout: []business.PayrollField{
+				{Field: business.PayrollName, Width: 6, Align: business.AlignLeft},
+				{Field: business.PayrollFrom, Width: 4, Align: business.AlignLeft},
+			},
+			want: "Anna A2026\n" +
+				"Bo, Jr2026\n",
+		},
+		{
+			name: "numbers are not cut off",
+			layout: []business.PayrollField{
+				{Field: business.PayrollEmployee, Width: 20, Align: business.AlignLeft},
+				{Field: business.PayrollTotal, Width: 6, Align: business.AlignRight},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := payrollFixed(testPayroll(tc.layout))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tc.wantErr)
+			}
+			if err == nil && string(got) != tc.want {
+				t.Errorf("got\n%q\nwant\n%q", got, tc.want)
+			}
+		})
+	}
+}
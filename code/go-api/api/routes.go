@@ -33,16 +33,85 @@ func registerRoutes(
 	e.PUT("/employee", handleCreateEmployee(eStore, logger))
 	e.DELETE("/employee/:email", handleDeleteEmployee(eStore, logger))
 	e.GET("/employee/:email", handleGetEmployee(eStore, logger))
+	e.PUT("/employee/:email/skills", handleSetEmployeeSkills(eStore, logger))
+	e.PUT("/employee/:email/wage", handleSetEmployeeWage(eStore, logger))
+	e.PUT("/employee/:email/agreement", handleSetEmployeeAgreement(bStore, eStore, logger))
 	e.GET("/employee/:email/availability", handleGetEmployeeAvailability(eStore, logger))
 	e.GET("/employees", handleGetAllEmployees(eStore, logger))
 	e.GET("/employees/availability/week/:week", handleGetAllEmployeeAvailabilityForWeek(eStore, logger))
 	e.PUT("/employee/:email/availability/:week", handleSetAvaMeep(eStore, logger))
+	e.GET("/employee/:email/availability/patterns", handleGetAvailabilityPatterns(eStore, logger))
+	e.PUT("/employee/:email/availability/patterns", handleSetAvailabilityPatterns(eStore, logger))
+	e.POST("/employee/:email/leave", handleCreateLeave(eStore, bStore, logger))
+	e.GET("/leave", handleGetLeaveRequests(eStore, logger))
+	e.GET("/leave/:id", handleGetLeave(eStore, bStore, logger))
+	e.POST("/leave/:id/decision", handleDecideLeave(eStore, bStore, logger))
+	e.POST("/leave/:id/cancel", handleCancelLeave(eStore, logger))
+	e.GET("/employee/:email/vacation", handleGetVacationBalance(eStore, bStore, logger))
+	e.GET("/employees/vacation", handleGetVacationBalances(eStore, bStore, logger))
+	e.POST("/employee/:email/punches", handlePunch(bStore, eStore, logger))
+	e.GET("/employee/:email/time-entries", handleGetTimeEntries(bStore, logger))
+	e.GET("/employee/:email/timesheet", handleGetTimesheet(bStore, logger))
+	e.POST("/employee/:email/timesheet/decision", handleDecideTimesheet(bStore, logger))
+	e.GET("/time-entries/:id", handleGetTimeEntry(bStore, logger))
+	e.PUT("/time-entries/:id", handleCorrectTimeEntry(bStore, logger))
 
 	e.GET("/business/timetable", handleGetTimetable(bStore, logger))
 	e.GET("/business/timetable/default", handleGetDefaultTimetable(bStore, logger))
 	e.PUT("/business/timetable/default", handleSetDefaultTimetable(bStore, logger))
-	e.PUT("/business/schedule/:week", handleCreateScheduleForWeek(bStore, logger))
+	e.GET("/business/timetable/overrides", handleGetTimetableOverrides(bStore, logger))
+	e.GET("/business/timetable/overrides/:kind/:date", handleGetTimetableOverride(bStore, logger))
+	e.PUT("/business/timetable/overrides/:kind/:date", handleSetTimetableOverride(bStore, logger))
+	e.DELETE("/business/timetable/overrides/:kind/:date", handleDeleteTimetableOverride(bStore, logger))
+	e.GET("/business/timetable/templates", handleGetTemplates(bStore, logger))
+	e.GET("/business/timetable/templates/:name", handleGetTemplate(bStore, logger))
+	e.PUT("/business/timetable/templates/:name", handleSetTemplate(bStore, logger))
+	e.DELETE("/business/timetable/templates/:name", handleDeleteTemplate(bStore, logger))
+	e.POST("/business/timetable/templates/:name/clone", handleCloneTemplate(bStore, logger))
+	e.GET("/business/timetable/assignments", handleGetTemplateAssignments(bStore, logger))
+	e.PUT("/business/timetable/assignments", handleSetTemplateAssignments(bStore, logger))
+	e.PUT("/business/schedule/:week", handleCreateScheduleForWeek(bStore, eStore, logger))
 	e.GET("/business/schedule/:week", handleGetScheduleForWeek(bStore, logger))
+	e.POST("/business/schedule/:week/generate", handleGenerateScheduleForWeek(bStore, eStore, logger))
+	e.POST("/business/schedule/:week/validate", handleValidateScheduleForWeek(bStore, eStore, logger))
+	e.GET("/business/schedule/:week/compliance", handleGetComplianceForWeek(bStore, logger))
+	e.GET("/business/schedule/:week/cost", handleGetScheduleCost(bStore, eStore, logger))
+	e.POST("/business/schedule/:week/cost", handleEstimateScheduleCost(bStore, eStore, logger))
+	e.GET("/business/schedule/:week/versions", handleGetScheduleVersions(bStore, logger))
+	e.GET("/business/schedule/:week/versions/:version", handleGetScheduleVersion(bStore, logger))
+	e.POST("/business/schedule/:week/versions/:version/restore", handleRestoreScheduleVersion(bStore, logger))
+	e.GET("/business/schedule/:week/diff", handleDiffScheduleVersions(bStore, logger))
+	e.GET("/business/schedule/:week/status", handleGetScheduleStatus(bStore, logger))
+	e.POST("/business/schedule/:week/status", handleTransitionSchedule(bStore, logger))
+	e.GET("/business/schedule/:week/swaps", handleGetSwapsForWeek(bStore, logger))
+	e.POST("/business/swaps", handleCreateSwap(bStore, logger))
+	e.GET("/business/swaps/:id", handleGetSwap(bStore, logger))
+	e.POST("/business/swaps/:id/accept", handleAcceptSwap(bStore, eStore, logger))
+	e.POST("/business/swaps/:id/decision", handleDecideSwap(bStore, eStore, logger))
+	e.POST("/business/swaps/:id/cancel", handleCancelSwap(bStore, logger))
+	e.GET("/business/schedule/:week/open-shifts", handleGetOpenShifts(bStore, logger))
+	e.GET("/business/schedule/:week/claims", handleGetClaimsForWeek(bStore, logger))
+	e.POST("/business/claims", handleClaimShift(bStore, eStore, logger))
+	e.GET("/business/claims/:id", handleGetClaim(bStore, logger))
+	e.POST("/business/claims/:id/decision", handleDecideClaim(bStore, eStore, logger))
+	e.POST("/business/claims/:id/cancel", handleCancelClaim(bStore, logger))
+	e.GET("/business/coverage", handleGetCoverage(bStore, eStore, logger))
+	e.GET("/business/rules", handleGetRuleSettings(bStore, logger))
+	e.PUT("/business/rules", handleSetRuleSettings(bStore, logger))
+	e.GET("/business/settings", handleGetSettings(bStore, logger))
+	e.PUT("/business/settings", handleSetSettings(bStore, logger))
+	e.GET("/business/holidays", handleGetHolidays(bStore, logger))
+	e.GET("/business/holidays/settings", handleGetHolidaySettings(bStore, logger))
+	e.PUT("/business/holidays/settings", handleSetHolidaySettings(bStore, logger))
+	e.GET("/business/vacation/policy", handleGetVacationPolicy(bStore, logger))
+	e.PUT("/business/vacation/policy", handleSetVacationPolicy(bStore, logger))
+	e.GET("/business/budget", handleGetLaborBudget(bStore, logger))
+	e.PUT("/business/budget", handleSetLaborBudget(bStore, logger))
+	e.GET("/business/supplements", handleGetSupplementSettings(bStore, logger))
+	e.PUT("/business/supplements", handleSetSupplementSettings(bStore, logger))
+	e.GET("/business/payroll", handleExportPayroll(bStore, eStore, logger))
+	e.GET("/business/payroll/settings", handleGetPayrollSettings(bStore, logger))
+	e.PUT("/business/payroll/settings", handleSetPayrollSettings(bStore, logger))
 
 	e.Any("/query", func(ctx echo.Context) error {
 		// ctx.Request().Header.Set("Content-Type", "application/json")
@@ -61,6 +130,15 @@ type claims struct {
 	Username string `json:"sub"`
 }
 
+// actor returns who is making the request: the user of the token if the
+// request is authenticated, else the X-User header.
+func actor(ctx echo.Context) string {
+	if c, ok := ctx.Get("claims").(claims); ok && c.Username != "" {
+		return c.Username
+	}
+	return ctx.Request().Header.Get("X-User")
+}
+
 func requireUser(config *viper.Viper, logger *log.Logger) echo.MiddlewareFunc {
 	client := &http.Client{
 		Transport: &http.Transport{
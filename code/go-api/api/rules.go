@@ -0,0 +1,107 @@
+package api
+
+import (
+	"airdock/store/business"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+)
+
+func handleGetRuleSettings(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		rs, err := bStore.GetRuleSettings(ctx.Request().Context())
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, rs)
+	}
+}
+
+func handleSetRuleSettings(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type profile struct {
+		MinDailyRestHours  float64 `json:"minDailyRestHours" validate:"gte=0,lte=24"`
+		MinWeeklyRestHours float64 `json:"minWeeklyRestHours" validate:"gte=0,lte=168"`
+		MaxWeeklyHours     float64 `json:"maxWeeklyHours" validate:"gte=0,lte=168"`
+		MaxConsecutiveDays int     `json:"maxConsecutiveDays" validate:"gte=0"`
+	}
+	type request struct {
+		Active   string             `json:"active" validate:"required"`
+		Profiles map[string]profile `json:"profiles" validate:"required,dive"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		rs := business.RuleSettings{
+			Active:   req.Active,
+			Profiles: make(map[string]business.RuleProfile, len(req.Profiles)),
+		}
+		for name, p := range req.Profiles {
+			rs.Profiles[name] = business.RuleProfile(p)
+		}
+		if _, ok := rs.Profiles[rs.Active]; !ok {
+			return ctx.String(http.StatusBadRequest, "active profile must be one of the given profiles")
+		}
+
+		err = bStore.SetRuleSettings(ctx.Request().Context(), rs)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, rs)
+	}
+}
+
+func handleGetComplianceForWeek(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Week string `param:"week" validate:"required,datetime=2006-01-02"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		week, err := time.ParseInLocation(time.DateOnly, req.Week, bStore.Location())
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest, err)
+		}
+
+		schedule, err := bStore.GetScheduleForWeek(ctx.Request().Context(), week)
+		if errors.Is(err, business.ErrConfigNotFound) {
+			return ctx.String(http.StatusNotFound, "no schedule for this week")
+		}
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err)
+		}
+
+		report, err := bStore.CheckComplianceForWeek(ctx.Request().Context(), week, schedule)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err)
+		}
+
+		return ctx.JSON(http.StatusOK, report)
+	}
+}
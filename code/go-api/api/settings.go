@@ -0,0 +1,74 @@
+package api
+
+import (
+	"airdock/store/business"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+)
+
+func handleGetSettings(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		s, err := bStore.GetSettings(ctx.Request().Context())
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, s)
+	}
+}
+
+// handleSetSettings changes the settings that are given and keeps the rest.
+func handleSetSettings(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Timezone             *string `json:"timezone" validate:"omitempty,min=1"`
+		Currency             *string `json:"currency" validate:"omitempty,len=3,uppercase"`
+		RequireSwapApproval  *bool   `json:"requireSwapApproval"`
+		RequireClaimApproval *bool   `json:"requireClaimApproval"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		s, err := bStore.GetSettings(ctx.Request().Context())
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		if req.Timezone != nil {
+			_, err = time.LoadLocation(*req.Timezone)
+			if err != nil {
+				return ctx.String(http.StatusBadRequest, "unknown time zone, expected an IANA name like Europe/Stockholm")
+			}
+			s.Timezone = *req.Timezone
+		}
+		if req.Currency != nil {
+			s.Currency = *req.Currency
+		}
+		if req.RequireSwapApproval != nil {
+			s.RequireSwapApproval = *req.RequireSwapApproval
+		}
+		if req.RequireClaimApproval != nil {
+			s.RequireClaimApproval = *req.RequireClaimApproval
+		}
+
+		err = bStore.SetSettings(ctx.Request().Context(), s)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, s)
+	}
+}
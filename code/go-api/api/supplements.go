@@ -0,0 +1,93 @@
+package api
+
+import (
+	"airdock/store"
+	"airdock/store/business"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+)
+
+func handleGetSupplementSettings(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		ss, err := bStore.GetSupplementSettings(ctx.Request().Context())
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, ss)
+	}
+}
+
+// handleSetSupplementSettings replaces the supplement rules of all
+// agreements. Every rule pays either a percent of the hourly wage or a fixed
+// amount per hour.
+func handleSetSupplementSettings(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type rule struct {
+		Name     string   `json:"name" validate:"required"`
+		From     string   `json:"from" validate:"required,datetime=15:04"`
+		To       string   `json:"to" validate:"required,datetime=15:04"`
+		Weekdays []string `json:"weekdays" validate:"dive,oneof=monday tuesday wednesday thursday friday saturday sunday"`
+		Holidays bool     `json:"holidays"`
+		Percent  float64  `json:"percent" validate:"gte=0,lte=1000"`
+		Amount   int64    `json:"amount" validate:"gte=0"`
+	}
+	type request struct {
+		Default    string            `json:"default" validate:"required"`
+		Agreements map[string][]rule `json:"agreements" validate:"required,dive,dive"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		ss := business.SupplementSettings{
+			Default:    req.Default,
+			Agreements: make(map[string][]business.SupplementRule, len(req.Agreements)),
+		}
+		for name, rules := range req.Agreements {
+			ss.Agreements[name] = make([]business.SupplementRule, 0, len(rules))
+			for _, r := range rules {
+				if (r.Percent != 0) == (r.Amount != 0) {
+					return ctx.String(http.StatusBadRequest, "every rule needs either a percent or an amount")
+				}
+				from, err := store.ParseClock(r.From)
+				if err != nil {
+					return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+				}
+				to, err := store.ParseClock(r.To)
+				if err != nil {
+					return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+				}
+				ss.Agreements[name] = append(ss.Agreements[name], business.SupplementRule{
+					Name:     r.Name,
+					From:     from,
+					To:       to,
+					Weekdays: r.Weekdays,
+					Holidays: r.Holidays,
+					Percent:  r.Percent,
+					Amount:   r.Amount,
+				})
+			}
+		}
+		if _, ok := ss.Agreements[ss.Default]; !ok {
+			return ctx.String(http.StatusBadRequest, "default agreement must be one of the given agreements")
+		}
+
+		err = bStore.SetSupplementSettings(ctx.Request().Context(), ss)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, ss)
+	}
+}
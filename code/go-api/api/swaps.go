@@ -0,0 +1,272 @@
+package api
+
+import (
+	"airdock/store"
+	"airdock/store/business"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+)
+
+func handleGetSwapsForWeek(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Week string `param:"week" validate:"required,datetime=2006-01-02"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		week, err := time.ParseInLocation(time.DateOnly, req.Week, bStore.Location())
+		if err != nil {
+			return ctx.String(http.StatusBadRequest, "invalid date format, expected format is YYYY-MM-DD")
+		}
+
+		swaps, err := bStore.Swaps(ctx.Request().Context(), week)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, swaps)
+	}
+}
+
+func handleGetSwap(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		ID string `param:"id" validate:"required,uuid"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		sr, err := bStore.GetSwap(ctx.Request().Context(), req.ID)
+		if err != nil {
+			return swapError(ctx, logger, err)
+		}
+
+		return ctx.JSON(http.StatusOK, sr)
+	}
+}
+
+// handleCreateSwap lets an employee offer their place on a shift, to anyone
+// or only to the colleague given as "target".
+func handleCreateSwap(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Date    string `json:"date" validate:"required,datetime=2006-01-02"`
+		From    string `json:"from" validate:"required,datetime=15:04"`
+		To      string `json:"to" validate:"required,datetime=15:04"`
+		Offerer string `json:"offerer" validate:"required,email"`
+		Target  string `json:"target" validate:"omitempty,email,nefield=Offerer"`
+		Note    string `json:"note" validate:"max=500"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		from, err := store.ParseClock(req.From)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		to, err := store.ParseClock(req.To)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		sr, err := bStore.CreateSwap(ctx.Request().Context(), business.SwapRequest{
+			Date:    req.Date,
+			From:    from,
+			To:      to,
+			Offerer: req.Offerer,
+			Target:  req.Target,
+			Note:    req.Note,
+		})
+		if err != nil {
+			return swapError(ctx, logger, err)
+		}
+
+		return ctx.JSON(http.StatusCreated, sr)
+	}
+}
+
+// handleAcceptSwap lets "employee" take over the offered place. If that would
+// break availability, skills or working-time rules the violations are
+// returned with 422 Unprocessable Entity.
+func handleAcceptSwap(bStore *business.BusinessStore, eStore *store.EmployeeStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		ID       string `param:"id" validate:"required,uuid"`
+		Employee string `json:"employee" validate:"required,email"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		employees, ava, err := swapContext(ctx.Request().Context(), bStore, eStore, req.ID)
+		if err != nil {
+			return swapError(ctx, logger, err)
+		}
+
+		sr, violations, err := bStore.AcceptSwap(ctx.Request().Context(), req.ID, req.Employee, employees, ava)
+		if err != nil {
+			return swapError(ctx, logger, err)
+		}
+		if len(violations) > 0 {
+			return ctx.JSON(http.StatusUnprocessableEntity, business.ValidationResult{
+				Valid:      false,
+				Violations: violations,
+			})
+		}
+
+		return ctx.JSON(http.StatusOK, sr)
+	}
+}
+
+func handleDecideSwap(bStore *business.BusinessStore, eStore *store.EmployeeStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		ID      string `param:"id" validate:"required,uuid"`
+		Approve *bool  `json:"approve" validate:"required"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		employees, ava, err := swapContext(ctx.Request().Context(), bStore, eStore, req.ID)
+		if err != nil {
+			return swapError(ctx, logger, err)
+		}
+
+		sr, violations, err := bStore.DecideSwap(ctx.Request().Context(), req.ID, *req.Approve, actor(ctx), employees, ava)
+		if err != nil {
+			return swapError(ctx, logger, err)
+		}
+		if len(violations) > 0 {
+			return ctx.JSON(http.StatusUnprocessableEntity, business.ValidationResult{
+				Valid:      false,
+				Violations: violations,
+			})
+		}
+
+		return ctx.JSON(http.StatusOK, sr)
+	}
+}
+
+func handleCancelSwap(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		ID string `param:"id" validate:"required,uuid"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		sr, err := bStore.CancelSwap(ctx.Request().Context(), req.ID)
+		if err != nil {
+			return swapError(ctx, logger, err)
+		}
+
+		return ctx.JSON(http.StatusOK, sr)
+	}
+}
+
+// swapContext loads the employees and their availability for the week of a
+// swap request, which are needed to check the swap.
+func swapContext(
+	ctx context.Context,
+	bStore *business.BusinessStore,
+	eStore *store.EmployeeStore,
+	id string,
+) ([]store.Employee, map[string]store.WeekAvailability, error) {
+	sr, err := bStore.GetSwap(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	week, err := time.ParseInLocation(time.DateOnly, sr.Week, bStore.Location())
+	if err != nil {
+		return nil, nil, err
+	}
+	return weekContext(ctx, eStore, week)
+}
+
+// weekContext loads the employees and their availability for the week
+// containing week.
+func weekContext(ctx context.Context, eStore *store.EmployeeStore, week time.Time) ([]store.Employee, map[string]store.WeekAvailability, error) {
+	employees, err := eStore.All(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	ava, err := eStore.GetAllEmployeesAvailabilityForWeek(ctx, week)
+	if err != nil {
+		return nil, nil, err
+	}
+	return employees, ava.Weeks, nil
+}
+
+func swapError(ctx echo.Context, logger *log.Logger, err error) error {
+	switch {
+	case errors.Is(err, business.ErrSwapNotFound):
+		return echo.NewHTTPError(http.StatusNotFound)
+	case errors.Is(err, business.ErrNotSwapTarget):
+		return ctx.String(http.StatusForbidden, err.Error())
+	case errors.Is(err, business.ErrNotAssigned),
+		errors.Is(err, business.ErrOwnSwap):
+		return ctx.String(http.StatusBadRequest, err.Error())
+	case errors.Is(err, business.ErrSwapClosed),
+		errors.Is(err, business.ErrSwapChanged),
+		errors.Is(err, business.ErrAlreadyAssigned),
+		errors.Is(err, business.ErrScheduleLocked):
+		return ctx.String(http.StatusConflict, err.Error())
+	default:
+		logger.Warn(err)
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,218 @@
+package api
+
+import (
+	"airdock/store/business"
+	"errors"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+)
+
+func handleGetTemplates(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		templates, err := bStore.Templates(ctx.Request().Context())
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, templates)
+	}
+}
+
+func handleGetTemplate(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Name string `param:"name" validate:"required,max=64"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		t, err := bStore.GetTemplate(ctx.Request().Context(), req.Name)
+		if errors.Is(err, business.ErrTemplateNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, t)
+	}
+}
+
+// handleSetTemplate creates a template or replaces an existing one. A template
+// has either a "timetable" repeated every week or a "cycle" of weeks rotating
+// from the week of "anchor".
+func handleSetTemplate(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Name        string                       `param:"name" validate:"required,max=64"`
+		Description string                       `json:"description"`
+		Timetable   *setDefaultTimetableRequest  `json:"timetable" validate:"required_without=Cycle,excluded_with=Cycle"`
+		Cycle       []setDefaultTimetableRequest `json:"cycle" validate:"required_without=Timetable,omitempty,max=52,dive"`
+		Anchor      string                       `json:"anchor" validate:"required_with=Cycle,omitempty,datetime=2006-01-02"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		t := business.TimetableTemplate{
+			Name:        req.Name,
+			Description: req.Description,
+			Anchor:      req.Anchor,
+		}
+		if req.Timetable != nil {
+			t.Timetable, err = req.Timetable.mapToBusiness()
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+		}
+		for _, week := range req.Cycle {
+			wtt, err := week.mapToBusiness()
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+			t.Cycle = append(t.Cycle, wtt)
+		}
+
+		t, err = bStore.SetTemplate(ctx.Request().Context(), t)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, t)
+	}
+}
+
+func handleCloneTemplate(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Name    string `param:"name" validate:"required,max=64"`
+		NewName string `json:"name" validate:"required,max=64"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		t, err := bStore.CloneTemplate(ctx.Request().Context(), req.Name, req.NewName)
+		if errors.Is(err, business.ErrTemplateNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+		if errors.Is(err, business.ErrTemplateExists) {
+			return ctx.String(http.StatusConflict, "a template with this name already exists")
+		}
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusCreated, t)
+	}
+}
+
+func handleDeleteTemplate(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Name string `param:"name" validate:"required,max=64"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		err = bStore.DeleteTemplate(ctx.Request().Context(), req.Name)
+		if errors.Is(err, business.ErrTemplateNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+		if errors.Is(err, business.ErrTemplateInUse) {
+			return ctx.String(http.StatusConflict, err.Error())
+		}
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.NoContent(http.StatusOK)
+	}
+}
+
+func handleGetTemplateAssignments(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		assignments, err := bStore.GetTemplateAssignments(ctx.Request().Context())
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, assignments)
+	}
+}
+
+// handleSetTemplateAssignments replaces the date ranges templates are
+// scheduled for. Weeks not covered by any range use the default timetable.
+func handleSetTemplateAssignments(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type assignment struct {
+		Template string `json:"template" validate:"required,max=64"`
+		From     string `json:"from" validate:"required,datetime=2006-01-02"`
+		To       string `json:"to" validate:"omitempty,datetime=2006-01-02"`
+	}
+	type request struct {
+		Assignments []assignment `json:"assignments" validate:"required,dive"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		assignments := make([]business.TemplateAssignment, 0, len(req.Assignments))
+		for _, a := range req.Assignments {
+			assignments = append(assignments, business.TemplateAssignment(a))
+		}
+
+		assignments, err = bStore.SetTemplateAssignments(ctx.Request().Context(), assignments)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, assignments)
+	}
+}
@@ -0,0 +1,293 @@
+package api
+
+import (
+	"airdock/store"
+	"airdock/store/business"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+)
+
+// maxTimesheetDays limits the period of a timesheet.
+const maxTimesheetDays = 92
+
+type shiftRef struct {
+	Date string `json:"date" validate:"required,datetime=2006-01-02"`
+	From string `json:"from" validate:"required,datetime=15:04"`
+	To   string `json:"to" validate:"required,datetime=15:04"`
+}
+
+// handlePunch records a clock-in, clock-out or the start or end of a break,
+// now unless "at" is given.
+func handlePunch(bStore *business.BusinessStore, eStore *store.EmployeeStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Email string     `param:"email" validate:"required,email"`
+		Kind  string     `json:"kind" validate:"required,oneof=clock_in clock_out break_start break_end"`
+		At    *time.Time `json:"at"`
+		Shift *shiftRef  `json:"shift" validate:"omitempty"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		at := time.Now()
+		if req.At != nil {
+			if req.At.After(at) {
+				return ctx.String(http.StatusBadRequest, "punches must not be in the future")
+			}
+			at = *req.At
+		}
+
+		var shift *business.ShiftRef
+		if req.Shift != nil {
+			if req.Kind != business.PunchClockIn {
+				return ctx.String(http.StatusBadRequest, "only clock-ins may name a shift")
+			}
+			from, err := store.ParseClock(req.Shift.From)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+			to, err := store.ParseClock(req.Shift.To)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+			shift = &business.ShiftRef{
+				Date: req.Shift.Date,
+				From: from,
+				To:   to,
+			}
+		}
+
+		_, err = eStore.Get(ctx.Request().Context(), req.Email)
+		if errors.Is(err, store.ErrEmployeeNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		te, err := bStore.Punch(ctx.Request().Context(), req.Email, req.Kind, at, shift)
+		if err != nil {
+			return timeEntryError(ctx, logger, err)
+		}
+
+		return ctx.JSON(http.StatusOK, te)
+	}
+}
+
+func handleGetTimeEntries(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Email string `param:"email" validate:"required,email"`
+		From  string `query:"from" validate:"required,datetime=2006-01-02"`
+		To    string `query:"to" validate:"required,datetime=2006-01-02"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		entries, err := bStore.TimeEntries(ctx.Request().Context(), req.Email, req.From, req.To)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, entries)
+	}
+}
+
+func handleGetTimeEntry(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		ID string `param:"id" validate:"required,uuid"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		te, err := bStore.GetTimeEntry(ctx.Request().Context(), req.ID)
+		if err != nil {
+			return timeEntryError(ctx, logger, err)
+		}
+
+		return ctx.JSON(http.StatusOK, te)
+	}
+}
+
+// handleCorrectTimeEntry lets a manager replace the punches of a time entry,
+// e.g. to add a forgotten clock-out.
+func handleCorrectTimeEntry(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type breakTime struct {
+		Start time.Time  `json:"start" validate:"required"`
+		End   *time.Time `json:"end"`
+	}
+	type request struct {
+		ID       string      `param:"id" validate:"required,uuid"`
+		ClockIn  time.Time   `json:"clockIn" validate:"required"`
+		ClockOut *time.Time  `json:"clockOut"`
+		Breaks   []breakTime `json:"breaks" validate:"dive"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		breaks := make([]business.Break, 0, len(req.Breaks))
+		for _, b := range req.Breaks {
+			breaks = append(breaks, business.Break(b))
+		}
+
+		te, err := bStore.CorrectTimeEntry(ctx.Request().Context(), req.ID, req.ClockIn, req.ClockOut, breaks, actor(ctx))
+		if err != nil {
+			return timeEntryError(ctx, logger, err)
+		}
+
+		return ctx.JSON(http.StatusOK, te)
+	}
+}
+
+// handleGetTimesheet compares the work of an employee with the schedule,
+// per shift and per day, for the days from "from" up to and including "to".
+func handleGetTimesheet(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Email string `param:"email" validate:"required,email"`
+		From  string `query:"from" validate:"required,datetime=2006-01-02"`
+		To    string `query:"to" validate:"required,datetime=2006-01-02"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		from, to, err := timesheetPeriod(bStore, req.From, req.To)
+		if err != nil {
+			return ctx.String(http.StatusBadRequest, err.Error())
+		}
+
+		ts, err := bStore.Timesheet(ctx.Request().Context(), req.Email, from, to)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, ts)
+	}
+}
+
+// handleDecideTimesheet approves or rejects the timesheet of an employee for
+// a period. Time entries of approved timesheets can no longer be changed.
+func handleDecideTimesheet(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Email   string `param:"email" validate:"required,email"`
+		From    string `json:"from" validate:"required,datetime=2006-01-02"`
+		To      string `json:"to" validate:"required,datetime=2006-01-02"`
+		Approve *bool  `json:"approve" validate:"required"`
+		Note    string `json:"note" validate:"max=500"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		from, to, err := timesheetPeriod(bStore, req.From, req.To)
+		if err != nil {
+			return ctx.String(http.StatusBadRequest, err.Error())
+		}
+
+		ts, err := bStore.DecideTimesheet(ctx.Request().Context(), req.Email, from, to, *req.Approve, req.Note, actor(ctx))
+		if err != nil {
+			return timeEntryError(ctx, logger, err)
+		}
+
+		return ctx.JSON(http.StatusOK, ts)
+	}
+}
+
+func timesheetPeriod(bStore *business.BusinessStore, fromStr string, toStr string) (time.Time, time.Time, error) {
+	from, err := time.ParseInLocation(time.DateOnly, fromStr, bStore.Location())
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("invalid date format, expected format is YYYY-MM-DD")
+	}
+	to, err := time.ParseInLocation(time.DateOnly, toStr, bStore.Location())
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("invalid date format, expected format is YYYY-MM-DD")
+	}
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, errors.New("to must not be before from")
+	}
+	if to.After(from.AddDate(0, 0, maxTimesheetDays)) {
+		return time.Time{}, time.Time{}, errors.New("period must not be longer than 92 days")
+	}
+	return from, to, nil
+}
+
+func timeEntryError(ctx echo.Context, logger *log.Logger, err error) error {
+	switch {
+	case errors.Is(err, business.ErrTimeEntryNotFound):
+		return echo.NewHTTPError(http.StatusNotFound)
+	case errors.Is(err, business.ErrInvalidPunch),
+		errors.Is(err, business.ErrInvalidTimeEntry),
+		errors.Is(err, business.ErrPunchOutOfOrder):
+		return ctx.String(http.StatusBadRequest, err.Error())
+	case errors.Is(err, business.ErrAlreadyClockedIn),
+		errors.Is(err, business.ErrNotClockedIn),
+		errors.Is(err, business.ErrOnBreak),
+		errors.Is(err, business.ErrNotOnBreak),
+		errors.Is(err, business.ErrNotScheduled),
+		errors.Is(err, business.ErrTimesheetApproved),
+		errors.Is(err, business.ErrTimesheetChanged),
+		errors.Is(err, business.ErrTimeEntryChanged),
+		errors.Is(err, business.ErrOpenTimeEntries):
+		return ctx.String(http.StatusConflict, err.Error())
+	default:
+		logger.Warn(err)
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+}
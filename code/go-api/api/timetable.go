@@ -1,9 +1,12 @@
 package api
 
 import (
+	"airdock/store"
 	"airdock/store/business"
+	"context"
 	"errors"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/charmbracelet/log"
@@ -11,9 +14,10 @@ import (
 )
 
 type shift struct {
-	From              string `json:"from" validate:"required,datetime=15:04"`
-	To                string `json:"to" validate:"required,datetime=15:04"`
-	RequiredEmployees int    `json:"requiredEmployees" validate:"required,number"`
+	From              string         `json:"from" validate:"required,datetime=15:04"`
+	To                string         `json:"to" validate:"required,datetime=15:04"`
+	RequiredEmployees int            `json:"requiredEmployees" validate:"required,number"`
+	Roles             map[string]int `json:"roles" validate:"omitempty,dive,keys,required,endkeys,gt=0"`
 }
 type day struct {
 	Shifts []shift `json:"shifts" validate:"required,dive"`
@@ -82,19 +86,33 @@ func mapDay(day day) (business.DayTimetable, error) {
 func mapShifts(dtoShifts []shift) ([]business.ShiftTimetable, error) {
 	shifts := make([]business.ShiftTimetable, 0, len(dtoShifts))
 	for _, s := range dtoShifts {
-		from, err := time.Parse("15:04", s.From)
+		from, err := store.ParseClock(s.From)
 		if err != nil {
 			return nil, err
 		}
-		to, err := time.Parse("15:04", s.To)
+		to, err := store.ParseClock(s.To)
 		if err != nil {
 			return nil, err
 		}
 
+		// a to before from is an overnight shift ending the next day
+		if to == from {
+			return nil, errors.New("shift must not start and end at the same time")
+		}
+
+		roleTotal := 0
+		for _, n := range s.Roles {
+			roleTotal += n
+		}
+		if roleTotal > s.RequiredEmployees {
+			return nil, errors.New("roles require more employees than the shift")
+		}
+
 		stt := business.ShiftTimetable{
 			From:              from,
 			To:                to,
 			RequiredEmployees: s.RequiredEmployees,
+			Roles:             s.Roles,
 		}
 		shifts = append(shifts, stt)
 	}
@@ -161,12 +179,12 @@ func handleGetTimetable(bStore *business.BusinessStore, logger *log.Logger) echo
 			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 		}
 
-		from, err := time.Parse(time.DateOnly, req.From)
+		from, err := time.ParseInLocation(time.DateOnly, req.From, bStore.Location())
 		if err != nil {
 			logger.Warn(err)
 			return echo.NewHTTPError(http.StatusBadRequest, err)
 		}
-		to, err := time.Parse(time.DateOnly, req.To)
+		to, err := time.ParseInLocation(time.DateOnly, req.To, bStore.Location())
 		if err != nil {
 			logger.Warn(err)
 			return echo.NewHTTPError(http.StatusBadRequest, err)
@@ -182,7 +200,7 @@ func handleGetTimetable(bStore *business.BusinessStore, logger *log.Logger) echo
 	}
 }
 
-func handleCreateScheduleForWeek(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+func handleCreateScheduleForWeek(bStore *business.BusinessStore, eStore *store.EmployeeStore, logger *log.Logger) echo.HandlerFunc {
 	type shiftSchedule struct {
 		From      string   `json:"from" validate:"required,datetime=15:04"`
 		To        string   `json:"to" validate:"required,datetime=15:04"`
@@ -218,25 +236,46 @@ func handleCreateScheduleForWeek(bStore *business.BusinessStore, logger *log.Log
 
 		logger.Printf("creating schedule for week %v", req)
 
-		week, err := time.Parse(time.DateOnly, req.Week)
+		week, err := time.ParseInLocation(time.DateOnly, req.Week, bStore.Location())
 		if err != nil {
 			logger.Warn(err)
 			return echo.NewHTTPError(http.StatusBadRequest, err)
 		}
 
-		err = bStore.CreateScheduleForWeek(ctx.Request().Context(), week, req.Schedule)
+		strict, _ := strconv.ParseBool(ctx.QueryParam("strict"))
+		if strict {
+			res, err := validateSchedule(ctx.Request().Context(), bStore, eStore, week, req.Schedule)
+			if errors.Is(err, business.ErrConfigNotFound) {
+				return ctx.String(http.StatusNotFound, "default timetable not yet set")
+			}
+			if err != nil {
+				logger.Warn(err)
+				return echo.NewHTTPError(http.StatusInternalServerError, err)
+			}
+			if !res.Valid {
+				return ctx.JSON(http.StatusUnprocessableEntity, res)
+			}
+		}
+
+		sv, err := bStore.CreateScheduleForWeek(ctx.Request().Context(), week, req.Schedule, actor(ctx))
+		if errors.Is(err, business.ErrScheduleLocked) {
+			return ctx.String(http.StatusConflict, "the schedule of this week is locked")
+		}
 		if err != nil {
 			logger.Warn(err)
 			return echo.NewHTTPError(http.StatusInternalServerError, err)
 		}
 
-		return ctx.NoContent(http.StatusCreated)
+		return ctx.JSON(http.StatusCreated, sv)
 	}
 }
 
 func handleGetScheduleForWeek(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
 	type request struct {
 		Week string `param:"week" validate:"required,datetime=2006-01-02"`
+		// Draft also returns schedules that are not published yet, for
+		// managers working on them.
+		Draft bool `query:"draft"`
 	}
 	return func(ctx echo.Context) error {
 		var req request
@@ -250,12 +289,23 @@ func handleGetScheduleForWeek(bStore *business.BusinessStore, logger *log.Logger
 			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 		}
 
-		week, err := time.Parse(time.DateOnly, req.Week)
+		week, err := time.ParseInLocation(time.DateOnly, req.Week, bStore.Location())
 		if err != nil {
 			logger.Warn(err)
 			return echo.NewHTTPError(http.StatusBadRequest, err)
 		}
 
+		if !req.Draft {
+			ss, err := bStore.GetScheduleStatus(ctx.Request().Context(), week)
+			if err != nil {
+				logger.Warn(err)
+				return echo.NewHTTPError(http.StatusInternalServerError, err)
+			}
+			if !ss.Visible() {
+				return ctx.String(http.StatusNotFound, "the schedule of this week is not published yet")
+			}
+		}
+
 		schedule, err := bStore.GetScheduleForWeek(ctx.Request().Context(), week)
 		if err != nil {
 			logger.Warn(err)
@@ -265,3 +315,106 @@ func handleGetScheduleForWeek(bStore *business.BusinessStore, logger *log.Logger
 		return ctx.JSON(http.StatusOK, schedule)
 	}
 }
+
+func handleGenerateScheduleForWeek(bStore *business.BusinessStore, eStore *store.EmployeeStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Week string `param:"week" validate:"required,datetime=2006-01-02"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		week, err := time.ParseInLocation(time.DateOnly, req.Week, bStore.Location())
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest, err)
+		}
+
+		employees, err := eStore.All(ctx.Request().Context())
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err)
+		}
+
+		ava, err := eStore.GetAllEmployeesAvailabilityForWeek(ctx.Request().Context(), week)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err)
+		}
+
+		generated, err := bStore.GenerateScheduleForWeek(ctx.Request().Context(), week, employees, ava.Weeks)
+		if errors.Is(err, business.ErrConfigNotFound) {
+			return ctx.String(http.StatusNotFound, "default timetable not yet set")
+		}
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err)
+		}
+
+		return ctx.JSON(http.StatusOK, generated)
+	}
+}
+
+func handleValidateScheduleForWeek(bStore *business.BusinessStore, eStore *store.EmployeeStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Week     string                `param:"week" validate:"required,datetime=2006-01-02"`
+		Schedule business.WeekSchedule `json:"schedule" validate:"required"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		week, err := time.ParseInLocation(time.DateOnly, req.Week, bStore.Location())
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest, err)
+		}
+
+		res, err := validateSchedule(ctx.Request().Context(), bStore, eStore, week, req.Schedule)
+		if errors.Is(err, business.ErrConfigNotFound) {
+			return ctx.String(http.StatusNotFound, "default timetable not yet set")
+		}
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err)
+		}
+
+		return ctx.JSON(http.StatusOK, res)
+	}
+}
+
+func validateSchedule(
+	ctx context.Context,
+	bStore *business.BusinessStore,
+	eStore *store.EmployeeStore,
+	week time.Time,
+	ws business.WeekSchedule,
+) (business.ValidationResult, error) {
+	employees, err := eStore.All(ctx)
+	if err != nil {
+		return business.ValidationResult{}, err
+	}
+
+	ava, err := eStore.GetAllEmployeesAvailabilityForWeek(ctx, week)
+	if err != nil {
+		return business.ValidationResult{}, err
+	}
+
+	return bStore.ValidateScheduleForWeek(ctx, week, ws, employees, ava.Weeks)
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"airdock/store"
+	"airdock/store/business"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/labstack/echo/v4"
+)
+
+func handleGetVacationPolicy(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		vp, err := bStore.GetVacationPolicy(ctx.Request().Context())
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, vp)
+	}
+}
+
+func handleSetVacationPolicy(bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		AnnualDays   float64 `json:"annualDays" validate:"gte=0,lte=366"`
+		Accrual      string  `json:"accrual" validate:"required,oneof=yearly monthly"`
+		Prorate      bool    `json:"prorate"`
+		MaxCarryOver float64 `json:"maxCarryOver" validate:"gte=0"`
+		WorkdayHours float64 `json:"workdayHours" validate:"gt=0,lte=24"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		vp := business.VacationPolicy(req)
+		err = bStore.SetVacationPolicy(ctx.Request().Context(), vp)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, vp)
+	}
+}
+
+// handleGetVacationBalances lists the vacation balance of every employee in
+// "year", by default the current one.
+func handleGetVacationBalances(eStore *store.EmployeeStore, bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Year int `query:"year" validate:"omitempty,gte=1970,lte=9999"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		year := req.Year
+		if year == 0 {
+			year = time.Now().In(bStore.Location()).Year()
+		}
+
+		employees, err := eStore.All(ctx.Request().Context())
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+		leave, err := eStore.LeaveRequests(ctx.Request().Context(), store.LeaveFilter{
+			To: fmt.Sprintf("%04d-12-31", year),
+		})
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		balances, err := bStore.VacationBalances(ctx.Request().Context(), year, employees, leave)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, balances)
+	}
+}
+
+func handleGetVacationBalance(eStore *store.EmployeeStore, bStore *business.BusinessStore, logger *log.Logger) echo.HandlerFunc {
+	type request struct {
+		Email string `param:"email" validate:"required,email"`
+		Year  int    `query:"year" validate:"omitempty,gte=1970,lte=9999"`
+	}
+	return func(ctx echo.Context) error {
+		var req request
+		err := ctx.Bind(&req)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		err = ctx.Validate(req)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		year := req.Year
+		if year == 0 {
+			year = time.Now().In(bStore.Location()).Year()
+		}
+
+		employee, err := eStore.Get(ctx.Request().Context(), req.Email)
+		if errors.Is(err, store.ErrEmployeeNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+		leave, err := eStore.LeaveRequests(ctx.Request().Context(), store.LeaveFilter{
+			Employee: req.Email,
+			To:       fmt.Sprintf("%04d-12-31", year),
+		})
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		balances, err := bStore.VacationBalances(ctx.Request().Context(), year, []store.Employee{employee}, leave)
+		if err != nil {
+			logger.Warn(err)
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return ctx.JSON(http.StatusOK, balances[0])
+	}
+}
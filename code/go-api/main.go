@@ -12,6 +12,7 @@ import (
 	"os"
 	"os/signal"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/charmbracelet/log"
 	"github.com/spf13/viper"
@@ -41,8 +42,10 @@ func run(ctx context.Context, w io.Writer, config *viper.Viper, _ []string) erro
 		return err
 	}
 	itemsStore := store.NewItemStore(mainBucket, logger)
-	eStore := store.NewEmployeeStore(mainBucket, logger)
-	bStore := business.NewBusinessStore(mainBucket, logger)
+	// the business store loads the configured time zone into tz
+	tz := store.NewTimeZone(time.UTC)
+	eStore := store.NewEmployeeStore(mainBucket, tz, logger)
+	bStore := business.NewBusinessStore(mainBucket, tz, logger)
 
 	server := api.NewServer(
 		config,
@@ -0,0 +1,281 @@
+package business
+
+import (
+	"airdock/store"
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+const (
+	LaborBudgetKey = "labor-budget"
+)
+
+const (
+	BudgetWeek  = "week"
+	BudgetMonth = "month"
+)
+
+// LaborBudget limits the labor cost of a week and of a calendar month, in
+// minor units of the business currency. Zero means no limit.
+type LaborBudget struct {
+	Weekly  int64 `json:"weekly"`
+	Monthly int64 `json:"monthly"`
+}
+
+func (bs *BusinessStore) GetLaborBudget(ctx context.Context) (LaborBudget, error) {
+	res, err := bs.configCol.Get(LaborBudgetKey, &gocb.GetOptions{
+		Context: ctx,
+	})
+	if errors.Is(err, gocb.ErrDocumentNotFound) {
+		return LaborBudget{}, nil
+	}
+	if err != nil {
+		return LaborBudget{}, err
+	}
+
+	var lb LaborBudget
+	err = res.Content(&lb)
+	return lb, err
+}
+
+func (bs *BusinessStore) SetLaborBudget(ctx context.Context, lb LaborBudget) error {
+	if lb.Weekly < 0 || lb.Monthly < 0 {
+		return errors.New("budgets must not be negative")
+	}
+	return bs.SetConfig(ctx, LaborBudgetKey, lb)
+}
+
+// ShiftCost is the cost of a scheduled shift, including the Supplements part
+// of it. Costs are in minor units of the business currency and shifts are
+// counted on the day they start.
+type ShiftCost struct {
+	From        store.Clock `json:"from"`
+	To          store.Clock `json:"to"`
+	Minutes     int         `json:"minutes"`
+	Supplements int64       `json:"supplements"`
+	Cost        int64       `json:"cost"`
+}
+
+type DayCost struct {
+	Date    string      `json:"date"`
+	Weekday string      `json:"weekday"`
+	Cost    int64       `json:"cost"`
+	Shifts  []ShiftCost `json:"shifts"`
+}
+
+type EmployeeCost struct {
+	Employee    string `json:"employee"`
+	Minutes     int    `json:"minutes"`
+	Supplements int64  `json:"supplements"`
+	Cost        int64  `json:"cost"`
+}
+
+// BudgetWarning is a period whose labor cost exceeds its budget. Start is the
+// first day of the week or month.
+type BudgetWarning struct {
+	Period string `json:"period"`
+	Start  string `json:"start"`
+	Budget int64  `json:"budget"`
+	Cost   int64  `json:"cost"`
+}
+
+// ScheduleCost is the labor cost of the schedule of a week.
+type ScheduleCost struct {
+	Week      string         `json:"week"`
+	Currency  string         `json:"currency"`
+	Total     int64          `json:"total"`
+	Days      []DayCost      `json:"days"`
+	Employees []EmployeeCost `json:"employees"`
+	// Supplements sums up the supplements of the week by rule.
+	Supplements []SupplementPay `json:"supplements"`
+	// MissingWages lists the scheduled employees without an hourly wage,
+	// whose hours cost nothing.
+	MissingWages []string        `json:"missingWages"`
+	Warnings     []BudgetWarning `json:"warnings"`
+}
+
+// ScheduleCost works out the cost of ws as the schedule of the week containing
+// week and warns about the budgets it exceeds. The monthly budget covers the
+// stored schedules of the other weeks of the month as well.
+func (bs *BusinessStore) ScheduleCost(ctx context.Context, week time.Time, ws WeekSchedule, employees []store.Employee) (ScheduleCost, error) {
+	settings, err := bs.GetSettings(ctx)
+	if err != nil {
+		return ScheduleCost{}, err
+	}
+	lb, err := bs.GetLaborBudget(ctx)
+	if err != nil {
+		return ScheduleCost{}, err
+	}
+
+	// a week may fall in two months
+	weekStart := store.StartOfWeek(week)
+	var months []time.Time
+	for i := range Weekdays {
+		date := weekStart.AddDate(0, 0, i)
+		month := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, date.Location())
+		if len(months) == 0 || !months[len(months)-1].Equal(month) {
+			months = append(months, month)
+		}
+	}
+	// the stored schedules of the months are costed as well, a week beyond
+	// their ends at most
+	pc, err := bs.PayCalculator(ctx, store.StartOfWeek(months[0]), months[len(months)-1].AddDate(0, 1, 7))
+	if err != nil {
+		return ScheduleCost{}, err
+	}
+
+	sc := CostSchedule(weekStart, ws, employees, pc)
+	sc.Currency = settings.Currency
+	if lb.Weekly > 0 && sc.Total > lb.Weekly {
+		sc.Warnings = append(sc.Warnings, BudgetWarning{
+			Period: BudgetWeek,
+			Start:  sc.Week,
+			Budget: lb.Weekly,
+			Cost:   sc.Total,
+		})
+	}
+	if lb.Monthly == 0 {
+		return sc, nil
+	}
+
+	for _, month := range months {
+		cost, err := bs.monthCost(ctx, month, weekStart, sc, employees, pc)
+		if err != nil {
+			return ScheduleCost{}, err
+		}
+		if cost > lb.Monthly {
+			sc.Warnings = append(sc.Warnings, BudgetWarning{
+				Period: BudgetMonth,
+				Start:  month.Format(time.DateOnly),
+				Budget: lb.Monthly,
+				Cost:   cost,
+			})
+		}
+	}
+	return sc, nil
+}
+
+// monthCost returns the cost of the days of month, taking the week starting
+// at weekStart from sc and the other weeks from the stored schedules.
+func (bs *BusinessStore) monthCost(ctx context.Context, month time.Time, weekStart time.Time, sc ScheduleCost, employees []store.Employee, pc PayCalculator) (int64, error) {
+	next := month.AddDate(0, 1, 0)
+	inMonth := func(dc DayCost) bool {
+		return dc.Date >= month.Format(time.DateOnly) && dc.Date < next.Format(time.DateOnly)
+	}
+
+	var cost int64
+	for week := store.StartOfWeek(month); week.Before(next); week = week.AddDate(0, 0, 7) {
+		weekCost := sc
+		if !week.Equal(weekStart) {
+			ws, err := bs.GetScheduleForWeek(ctx, week)
+			if errors.Is(err, ErrConfigNotFound) {
+				continue
+			}
+			if err != nil {
+				return 0, err
+			}
+			weekCost = CostSchedule(week, ws, employees, pc)
+		}
+
+		for _, dc := range weekCost.Days {
+			if inMonth(dc) {
+				cost += dc.Cost
+			}
+		}
+	}
+	return cost, nil
+}
+
+// CostSchedule works out the cost of ws from the hourly wages of the
+// employees and the supplements pc pays on top of them.
+func CostSchedule(week time.Time, ws WeekSchedule, employees []store.Employee, pc PayCalculator) ScheduleCost {
+	byEmail := make(map[string]store.Employee, len(employees))
+	for _, e := range employees {
+		byEmail[e.Email] = e
+	}
+
+	weekStart := store.StartOfWeek(week)
+	sc := ScheduleCost{
+		Week:         weekStart.Format(time.DateOnly),
+		Days:         []DayCost{},
+		Employees:    []EmployeeCost{},
+		Supplements:  []SupplementPay{},
+		MissingWages: []string{},
+		Warnings:     []BudgetWarning{},
+	}
+	byEmployee := make(map[string]*EmployeeCost)
+	missing := make(map[string]bool)
+	byRule := make(map[string]int)
+	for dayIdx, weekday := range Weekdays {
+		date := weekStart.AddDate(0, 0, dayIdx)
+		dc := DayCost{
+			Date:    date.Format(time.DateOnly),
+			Weekday: weekday.String(),
+			Shifts:  []ShiftCost{},
+		}
+
+		for _, s := range ws.Day(weekday).Shifts {
+			start, end := shiftInterval(date, s.From, s.To)
+			minutes := int(end.Sub(start).Minutes())
+			shc := ShiftCost{
+				From:    s.From,
+				To:      s.To,
+				Minutes: minutes * len(s.Employees),
+			}
+			for _, email := range s.Employees {
+				e, ok := byEmail[email]
+				if !ok || e.HourlyWage == 0 {
+					missing[email] = true
+					e.Email = email
+				}
+				p := pc.Pay(e, start, end)
+				shc.Cost += p.Total
+
+				ec, ok := byEmployee[email]
+				if !ok {
+					ec = &EmployeeCost{Employee: email}
+					byEmployee[email] = ec
+				}
+				ec.Minutes += minutes
+				ec.Cost += p.Total
+				for _, sp := range p.Supplements {
+					shc.Supplements += sp.Amount
+					ec.Supplements += sp.Amount
+
+					i, ok := byRule[sp.Rule]
+					if !ok {
+						i = len(sc.Supplements)
+						byRule[sp.Rule] = i
+						sc.Supplements = append(sc.Supplements, SupplementPay{Rule: sp.Rule})
+					}
+					sc.Supplements[i].Minutes += sp.Minutes
+					sc.Supplements[i].Amount += sp.Amount
+				}
+			}
+			dc.Cost += shc.Cost
+			dc.Shifts = append(dc.Shifts, shc)
+		}
+
+		sc.Total += dc.Cost
+		sc.Days = append(sc.Days, dc)
+	}
+
+	for _, email := range sortedKeys(byEmployee) {
+		sc.Employees = append(sc.Employees, *byEmployee[email])
+	}
+	for email := range missing {
+		sc.MissingWages = append(sc.MissingWages, email)
+	}
+	sort.Strings(sc.MissingWages)
+	return sc
+}
+
+// wageCost returns what minutes of work cost at an hourly wage, rounded to
+// the nearest minor unit.
+func wageCost(wage int64, minutes int) int64 {
+	return (wage*int64(minutes) + 30) / 60
+}
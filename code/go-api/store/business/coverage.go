@@ -0,0 +1,210 @@
+package business
+
+import (
+	"airdock/store"
+	"context"
+	"errors"
+	"time"
+)
+
+// ShiftCoverage is how well a scheduled shift covers its timetable shift.
+type ShiftCoverage struct {
+	Required     int            `json:"required"`
+	Assigned     int            `json:"assigned"`
+	Missing      int            `json:"missing"`
+	MissingRoles map[string]int `json:"missingRoles,omitempty"`
+}
+
+// CoverShift compares the employees and roles assigned in ss with what stt
+// requires. Employees without a role only count towards the part of
+// RequiredEmployees that is not tied to a role.
+func CoverShift(stt ShiftTimetable, ss ShiftSchedule) ShiftCoverage {
+	cov := ShiftCoverage{
+		Required: stt.RequiredEmployees,
+		Assigned: len(ss.Employees),
+		Missing:  max(stt.RequiredEmployees-len(ss.Employees), 0),
+	}
+
+	for _, role := range stt.RoleNames() {
+		if missing := stt.Roles[role] - len(ss.Roles[role]); missing > 0 {
+			if cov.MissingRoles == nil {
+				cov.MissingRoles = make(map[string]int)
+			}
+			cov.MissingRoles[role] = missing
+		}
+	}
+
+	return cov
+}
+
+const (
+	CoverageOK           = "ok"
+	CoverageUnderstaffed = "understaffed"
+	CoverageOverstaffed  = "overstaffed"
+	// CoverageImpossible is an understaffed shift that cannot be filled, as
+	// too few employees, or too few with a required skill, are available.
+	CoverageImpossible = "impossible"
+)
+
+// SlotCoverage compares a shift of the timetable with the schedule and with
+// how many employees are available for it. Shifts that are scheduled but not
+// in the timetable have no required employees.
+type SlotCoverage struct {
+	From store.Clock `json:"from"`
+	To   store.Clock `json:"to"`
+	ShiftCoverage
+	// Available is the number of employees whose availability covers the
+	// whole shift and AvailableRoles how many of them have the skill of each
+	// required role.
+	Available      int            `json:"available"`
+	AvailableRoles map[string]int `json:"availableRoles,omitempty"`
+	// Unknown is the number of employees whose availability is unknown for
+	// the day.
+	Unknown int    `json:"unknown"`
+	Status  string `json:"status"`
+}
+
+type DayCoverage struct {
+	Date    string         `json:"date"`
+	Weekday string         `json:"weekday"`
+	Shifts  []SlotCoverage `json:"shifts"`
+}
+
+// CoverageReport is the coverage of every day in a range, along with how many
+// shifts have each status other than ok.
+type CoverageReport struct {
+	From         string        `json:"from"`
+	To           string        `json:"to"`
+	Days         []DayCoverage `json:"days"`
+	Understaffed int           `json:"understaffed"`
+	Overstaffed  int           `json:"overstaffed"`
+	Impossible   int           `json:"impossible"`
+}
+
+// Coverage reports the coverage of every day from from up to and including to
+// by the stored schedules. ava holds the availability of the employees for
+// every week of the range, keyed by the date of its Monday and then by email.
+func (bs *BusinessStore) Coverage(
+	ctx context.Context,
+	from time.Time,
+	to time.Time,
+	employees []store.Employee,
+	ava map[string]map[string]store.WeekAvailability,
+) (CoverageReport, error) {
+	r, err := bs.timetableResolver(ctx, from, to)
+	if err != nil {
+		return CoverageReport{}, err
+	}
+
+	report := CoverageReport{
+		From: from.Format(time.DateOnly),
+		To:   to.Format(time.DateOnly),
+		Days: []DayCoverage{},
+	}
+	schedules := make(map[string]WeekSchedule)
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		weekStr := store.StartOfWeek(date).Format(time.DateOnly)
+		ws, ok := schedules[weekStr]
+		if !ok {
+			ws, err = bs.GetScheduleForWeek(ctx, date)
+			if err != nil && !errors.Is(err, ErrConfigNotFound) {
+				return CoverageReport{}, err
+			}
+			schedules[weekStr] = ws
+		}
+
+		dc := CoverDay(date, r.day(date), ws.Day(date.Weekday()), employees, ava[weekStr])
+		for _, sc := range dc.Shifts {
+			switch sc.Status {
+			case CoverageUnderstaffed:
+				report.Understaffed++
+			case CoverageOverstaffed:
+				report.Overstaffed++
+			case CoverageImpossible:
+				report.Impossible++
+			}
+		}
+		report.Days = append(report.Days, dc)
+	}
+	return report, nil
+}
+
+// CoverDay compares the shifts of the timetable of date with the schedule of
+// the day and the availability of the employees.
+func CoverDay(
+	date time.Time,
+	dtt DayTimetable,
+	ds DaySchedule,
+	employees []store.Employee,
+	ava map[string]store.WeekAvailability,
+) DayCoverage {
+	weekday := date.Weekday()
+	dc := DayCoverage{
+		Date:    date.Format(time.DateOnly),
+		Weekday: weekday.String(),
+		Shifts:  []SlotCoverage{},
+	}
+
+	for _, stt := range dtt.Shifts {
+		ss, _ := findScheduledShift(ds.Shifts, stt.From, stt.To)
+		sc := SlotCoverage{
+			From:          stt.From,
+			To:            stt.To,
+			ShiftCoverage: CoverShift(stt, ss),
+		}
+
+		for _, e := range employees {
+			wa := ava[e.Email]
+			if day := wa.Day(weekday); day.Availability == "" || day.Availability == store.AvailabilityUnknown {
+				sc.Unknown++
+				continue
+			}
+			if !coversShift(wa, weekday, stt.From, stt.To) {
+				continue
+			}
+			sc.Available++
+			for _, role := range stt.RoleNames() {
+				if e.HasSkill(role) {
+					if sc.AvailableRoles == nil {
+						sc.AvailableRoles = make(map[string]int)
+					}
+					sc.AvailableRoles[role]++
+				}
+			}
+		}
+
+		impossible := sc.Available < stt.RequiredEmployees
+		for _, role := range stt.RoleNames() {
+			if sc.AvailableRoles[role] < stt.Roles[role] {
+				impossible = true
+			}
+		}
+		switch {
+		case sc.Missing > 0 || len(sc.MissingRoles) > 0:
+			sc.Status = CoverageUnderstaffed
+			if impossible {
+				sc.Status = CoverageImpossible
+			}
+		case sc.Assigned > sc.Required:
+			sc.Status = CoverageOverstaffed
+		default:
+			sc.Status = CoverageOK
+		}
+		dc.Shifts = append(dc.Shifts, sc)
+	}
+
+	for _, ss := range ds.Shifts {
+		if _, ok := findShift(dtt, ss.From, ss.To); ok || len(ss.Employees) == 0 {
+			continue
+		}
+		dc.Shifts = append(dc.Shifts, SlotCoverage{
+			From: ss.From,
+			To:   ss.To,
+			ShiftCoverage: ShiftCoverage{
+				Assigned: len(ss.Employees),
+			},
+			Status: CoverageOverstaffed,
+		})
+	}
+	return dc
+}
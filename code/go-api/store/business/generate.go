@@ -0,0 +1,272 @@
+package business
+
+import (
+	"airdock/store"
+	"context"
+	"sort"
+	"time"
+)
+
+type UnfilledSlot struct {
+	Date     string      `json:"date"`
+	Weekday  string      `json:"weekday"`
+	From     store.Clock `json:"from"`
+	To       store.Clock `json:"to"`
+	Required int         `json:"required"`
+	Assigned int         `json:"assigned"`
+	// MissingRoles is how many employees are missing per required role.
+	MissingRoles map[string]int `json:"missingRoles,omitempty"`
+	// Unknown is the number of employees whose availability is unknown for
+	// the day and who were therefore not considered.
+	Unknown int    `json:"unknown"`
+	Reason  string `json:"reason"`
+}
+
+type GeneratedSchedule struct {
+	Schedule WeekSchedule   `json:"schedule"`
+	Unfilled []UnfilledSlot `json:"unfilled"`
+}
+
+func (bs *BusinessStore) GenerateScheduleForWeek(
+	ctx context.Context,
+	week time.Time,
+	employees []store.Employee,
+	ava map[string]store.WeekAvailability,
+) (GeneratedSchedule, error) {
+	tt, err := bs.timetableForWeek(ctx, week)
+	if err != nil {
+		return GeneratedSchedule{}, err
+	}
+
+	cc, err := bs.complianceContext(ctx, week)
+	if err != nil {
+		return GeneratedSchedule{}, err
+	}
+
+	return GenerateSchedule(week, tt, employees, ava, cc), nil
+}
+
+type slot struct {
+	dayIdx     int
+	shiftIdx   int
+	shift      ShiftTimetable
+	candidates []string
+	// preferred are the candidates who prefer to work the shift
+	preferred map[string]bool
+	unknown   int
+	blocked   int
+	// spare is the number of free candidates left over after filling
+	spare int
+}
+
+// GenerateSchedule fills the timetable of the week with the employees in ava,
+// keyed by email, without breaking availability, skills or the rules in cc.
+// The result only depends on the input.
+func GenerateSchedule(
+	week time.Time,
+	tt WeekTimetable,
+	employees []store.Employee,
+	ava map[string]store.WeekAvailability,
+	cc ComplianceContext,
+) GeneratedSchedule {
+	weekStart := store.StartOfWeek(week)
+
+	byEmail := make(map[string]store.Employee, len(employees))
+	for _, e := range employees {
+		byEmail[e.Email] = e
+	}
+
+	emails := make([]string, 0, len(ava))
+	for email := range ava {
+		emails = append(emails, email)
+	}
+	sort.Strings(emails)
+
+	var slots []*slot
+	for dayIdx, weekday := range Weekdays {
+		for shiftIdx, s := range tt.Day(weekday).Shifts {
+			sl := &slot{
+				dayIdx:    dayIdx,
+				shiftIdx:  shiftIdx,
+				shift:     s,
+				preferred: make(map[string]bool),
+			}
+			for _, email := range emails {
+				if ava[email].Day(weekday).Availability == store.AvailabilityUnknown {
+					sl.unknown++
+				} else if coversShift(ava[email], weekday, s.From, s.To) {
+					sl.candidates = append(sl.candidates, email)
+					sl.preferred[email] = prefersShift(ava[email], weekday, s.From, s.To)
+				}
+			}
+			slots = append(slots, sl)
+		}
+	}
+
+	ordered := make([]*slot, len(slots))
+	copy(ordered, slots)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := ordered[i], ordered[j]
+		slackA := len(a.candidates) - a.shift.RequiredEmployees
+		slackB := len(b.candidates) - b.shift.RequiredEmployees
+		if slackA != slackB {
+			return slackA < slackB
+		}
+		if a.dayIdx != b.dayIdx {
+			return a.dayIdx < b.dayIdx
+		}
+		return a.shiftIdx < b.shiftIdx
+	})
+
+	minutes := make(map[string]int, len(emails))
+	worked := workShiftsByEmployee(weekStart, WeekSchedule{}, cc)
+	assignments := make(map[*slot]ShiftSchedule, len(slots))
+	for _, sl := range ordered {
+		start, end := shiftInterval(weekStart.AddDate(0, 0, sl.dayIdx), sl.shift.From, sl.shift.To)
+		work := workShift{start: start, end: end}
+
+		free := make([]string, 0, len(sl.candidates))
+		for _, email := range sl.candidates {
+			if overlapsAny(worked[email], work) {
+				continue
+			}
+			if !cc.Profile.allows(weekStart, worked[email], work) {
+				sl.blocked++
+				continue
+			}
+			free = append(free, email)
+		}
+		sort.SliceStable(free, func(i, j int) bool {
+			if sl.preferred[free[i]] != sl.preferred[free[j]] {
+				return sl.preferred[free[i]]
+			}
+			if minutes[free[i]] != minutes[free[j]] {
+				return minutes[free[i]] < minutes[free[j]]
+			}
+			return free[i] < free[j]
+		})
+
+		ss := ShiftSchedule{
+			From:      sl.shift.From,
+			To:        sl.shift.To,
+			Employees: []string{},
+		}
+		used := make(map[string]bool, sl.shift.RequiredEmployees)
+		general := sl.shift.RequiredEmployees
+		for _, role := range sl.shift.RoleNames() {
+			need := sl.shift.Roles[role]
+			general -= need
+			for _, email := range free {
+				if need == 0 {
+					break
+				}
+				if used[email] || !byEmail[email].HasSkill(role) {
+					continue
+				}
+				if ss.Roles == nil {
+					ss.Roles = make(map[string][]string)
+				}
+				ss.Roles[role] = append(ss.Roles[role], email)
+				ss.Employees = append(ss.Employees, email)
+				used[email] = true
+				need--
+			}
+			sort.Strings(ss.Roles[role])
+		}
+		for _, email := range free {
+			if general <= 0 {
+				break
+			}
+			if used[email] {
+				continue
+			}
+			ss.Employees = append(ss.Employees, email)
+			used[email] = true
+			general--
+		}
+		sort.Strings(ss.Employees)
+		sl.spare = len(free) - len(ss.Employees)
+
+		for _, email := range ss.Employees {
+			minutes[email] += int(work.end.Sub(work.start).Minutes())
+			worked[email] = append(worked[email], work)
+			sortWorkShifts(worked[email])
+		}
+		assignments[sl] = ss
+	}
+
+	res := GeneratedSchedule{
+		Unfilled: []UnfilledSlot{},
+	}
+	for dayIdx, weekday := range Weekdays {
+		ds := DaySchedule{
+			Shifts: []ShiftSchedule{},
+		}
+		for _, sl := range slots {
+			if sl.dayIdx != dayIdx {
+				continue
+			}
+			ss := assignments[sl]
+			ds.Shifts = append(ds.Shifts, ss)
+
+			cov := CoverShift(sl.shift, ss)
+			if cov.Missing > 0 || len(cov.MissingRoles) > 0 {
+				reason := "not enough available employees"
+				if len(sl.candidates) == 0 {
+					reason = "no available employees"
+				} else if len(cov.MissingRoles) > 0 && sl.spare > 0 {
+					reason = "not enough available employees with the required skills"
+				} else if sl.blocked > 0 {
+					reason = "remaining available employees would break working-time rules"
+				}
+				res.Unfilled = append(res.Unfilled, UnfilledSlot{
+					Date:         weekStart.AddDate(0, 0, dayIdx).Format(time.DateOnly),
+					Weekday:      weekday.String(),
+					From:         sl.shift.From,
+					To:           sl.shift.To,
+					Required:     cov.Required,
+					Assigned:     cov.Assigned,
+					MissingRoles: cov.MissingRoles,
+					Unknown:      sl.unknown,
+					Reason:       reason,
+				})
+			}
+		}
+		res.Schedule.SetDay(weekday, ds)
+	}
+
+	return res
+}
+
+func overlapsAny(shifts []workShift, s workShift) bool {
+	for _, other := range shifts {
+		if overlaps(other, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// coversShift reports whether the availability covers a shift starting on
+// weekday, checking the part of an overnight shift against the next day.
+func coversShift(wa store.WeekAvailability, weekday time.Weekday, from store.Clock, to store.Clock) bool {
+	if !wa.Day(weekday).Covers(from, to) {
+		return false
+	}
+	if !isOvernight(from, to) || to == store.NewClock(0, 0) {
+		return true
+	}
+	return wa.NextDay(weekday).Covers(store.NewClock(0, 0), to)
+}
+
+// prefersShift reports whether the shift starting on weekday lies within the
+// preferred windows of the availability, like coversShift.
+func prefersShift(wa store.WeekAvailability, weekday time.Weekday, from store.Clock, to store.Clock) bool {
+	if wa.Day(weekday).Level(from, to) != store.LevelPreferred {
+		return false
+	}
+	if !isOvernight(from, to) || to == store.NewClock(0, 0) {
+		return true
+	}
+	return wa.NextDay(weekday).Level(store.NewClock(0, 0), to) == store.LevelPreferred
+}
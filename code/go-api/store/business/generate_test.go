@@ -0,0 +1,290 @@
+package business
+
+import (
+	"airdock/store"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testWeek is a Monday.
+var testWeek = time.Date(2026, time.October, 12, 0, 0, 0, 0, time.UTC)
+
+func clock(s string) store.Clock {
+	c, err := store.ParseClock(s)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func shift(from string, to string, required int) ShiftTimetable {
+	return ShiftTimetable{From: clock(from), To: clock(to), RequiredEmployees: required}
+}
+
+func day(availability string, windows ...store.AvailabilityWindow) store.DayAvilability {
+	return store.DayAvilability{Availability: availability, Windows: windows}
+}
+
+func window(from string, to string, level string) store.AvailabilityWindow {
+	return store.AvailabilityWindow{From: clock(from), To: clock(to), Level: level}
+}
+
+// week returns a week with every day set to da, except for the given days.
+func week(da store.DayAvilability, days map[time.Weekday]store.DayAvilability) store.WeekAvailability {
+	wa := store.WeekAvailability{
+		Monday:    da,
+		Tuesday:   da,
+		Wednesday: da,
+		Thursday:  da,
+		Friday:    da,
+		Saturday:  da,
+		Sunday:    da,
+	}
+	for weekday, da := range days {
+		switch weekday {
+		case time.Monday:
+			wa.Monday = da
+		case time.Tuesday:
+			wa.Tuesday = da
+		case time.Wednesday:
+			wa.Wednesday = da
+		case time.Thursday:
+			wa.Thursday = da
+		case time.Friday:
+			wa.Friday = da
+		case time.Saturday:
+			wa.Saturday = da
+		default:
+			wa.Sunday = da
+		}
+	}
+	return wa
+}
+
+func nextMonday(wa store.WeekAvailability, da store.DayAvilability) store.WeekAvailability {
+	wa.NextMonday = &da
+	return wa
+}
+
+func TestGenerateSchedule(t *testing.T) {
+	available := week(day(store.AvailabilityAvailable), nil)
+
+	tests := []struct {
+		name      string
+		tt        WeekTimetable
+		employees []store.Employee
+		ava       map[string]store.WeekAvailability
+		profile   RuleProfile
+		// want are the employees of every shift per weekday
+		want     map[time.Weekday][][]string
+		wantRole map[string][]string
+		unfilled []string
+	}{
+		{
+			name: "fills shift ordered by email",
+			tt:   WeekTimetable{Monday: DayTimetable{Shifts: []ShiftTimetable{shift("09:00", "17:00", 2)}}},
+			ava: map[string]store.WeekAvailability{
+				"c@x.se": available,
+				"a@x.se": available,
+				"b@x.se": available,
+			},
+			want: map[time.Weekday][][]string{time.Monday: {{"a@x.se", "b@x.se"}}},
+		},
+		{
+			name: "skips unavailable and unknown employees",
+			tt:   WeekTimetable{Monday: DayTimetable{Shifts: []ShiftTimetable{shift("09:00", "17:00", 2)}}},
+			ava: map[string]store.WeekAvailability{
+				"a@x.se": week(day(store.AvailabilityAvailable), map[time.Weekday]store.DayAvilability{
+					time.Monday: day(store.AvailabilityUnavailable),
+				}),
+				"b@x.se": week(day(store.AvailabilityUnknown), nil),
+				"c@x.se": available,
+			},
+			want:     map[time.Weekday][][]string{time.Monday: {{"c@x.se"}}},
+			unfilled: []string{"not enough available employees"},
+		},
+		{
+			name: "no available employees",
+			tt:   WeekTimetable{Monday: DayTimetable{Shifts: []ShiftTimetable{shift("09:00", "17:00", 1)}}},
+			ava: map[string]store.WeekAvailability{
+				"a@x.se": week(day(store.AvailabilityUnavailable), nil),
+			},
+			want:     map[time.Weekday][][]string{time.Monday: {{}}},
+			unfilled: []string{"no available employees"},
+		},
+		{
+			name: "partial availability must cover the whole shift",
+			tt:   WeekTimetable{Monday: DayTimetable{Shifts: []ShiftTimetable{shift("09:00", "17:00", 1)}}},
+			ava: map[string]store.WeekAvailability{
+				"a@x.se": week(day(store.AvailabilityPartial, window("09:00", "12:00", store.LevelAvailable)), nil),
+				"b@x.se": week(day(store.AvailabilityPartial, window("08:00", "18:00", store.LevelAvailable)), nil),
+			},
+			want: map[time.Weekday][][]string{time.Monday: {{"b@x.se"}}},
+		},
+		{
+			name: "roles go to employees with the skill",
+			tt: WeekTimetable{Monday: DayTimetable{Shifts: []ShiftTimetable{{
+				From:              clock("09:00"),
+				To:                clock("17:00"),
+				RequiredEmployees: 2,
+				Roles:             map[string]int{"cook": 1},
+			}}}},
+			employees: []store.Employee{
+				{Email: "a@x.se"},
+				{Email: "b@x.se"},
+				{Email: "c@x.se", Skills: []string{"cook"}},
+			},
+			ava: map[string]store.WeekAvailability{
+				"a@x.se": available,
+				"b@x.se": available,
+				"c@x.se": available,
+			},
+			want:     map[time.Weekday][][]string{time.Monday: {{"a@x.se", "c@x.se"}}},
+			wantRole: map[string][]string{"cook": {"c@x.se"}},
+		},
+		{
+			name: "missing skill",
+			tt: WeekTimetable{Monday: DayTimetable{Shifts: []ShiftTimetable{{
+				From:              clock("09:00"),
+				To:                clock("17:00"),
+				RequiredEmployees: 1,
+				Roles:             map[string]int{"cook": 1},
+			}}}},
+			employees: []store.Employee{{Email: "a@x.se"}},
+			ava:       map[string]store.WeekAvailability{"a@x.se": available},
+			want:      map[time.Weekday][][]string{time.Monday: {{}}},
+			unfilled:  []string{"not enough available employees with the required skills"},
+		},
+		{
+			name: "preferred employees first",
+			tt:   WeekTimetable{Monday: DayTimetable{Shifts: []ShiftTimetable{shift("09:00", "17:00", 1)}}},
+			ava: map[string]store.WeekAvailability{
+				"a@x.se": available,
+				"b@x.se": week(day(store.AvailabilityAvailable, window("08:00", "18:00", store.LevelPreferred)), nil),
+			},
+			want: map[time.Weekday][][]string{time.Monday: {{"b@x.se"}}},
+		},
+		{
+			name: "fewest assigned minutes first",
+			tt: WeekTimetable{
+				Monday:  DayTimetable{Shifts: []ShiftTimetable{shift("09:00", "17:00", 1)}},
+				Tuesday: DayTimetable{Shifts: []ShiftTimetable{shift("09:00", "17:00", 1)}},
+			},
+			ava: map[string]store.WeekAvailability{
+				"a@x.se": available,
+				"b@x.se": available,
+			},
+			want: map[time.Weekday][][]string{
+				time.Monday:  {{"a@x.se"}},
+				time.Tuesday: {{"b@x.se"}},
+			},
+		},
+		{
+			name: "scarce slots first",
+			tt: WeekTimetable{
+				Monday:  DayTimetable{Shifts: []ShiftTimetable{shift("09:00", "17:00", 1)}},
+				Tuesday: DayTimetable{Shifts: []ShiftTimetable{shift("09:00", "17:00", 1)}},
+			},
+			ava: map[string]store.WeekAvailability{
+				"a@x.se": available,
+				"b@x.se": week(day(store.AvailabilityAvailable), map[time.Weekday]store.DayAvilability{
+					time.Tuesday: day(store.AvailabilityUnavailable),
+				}),
+			},
+			want: map[time.Weekday][][]string{
+				time.Monday:  {{"b@x.se"}},
+				time.Tuesday: {{"a@x.se"}},
+			},
+		},
+		{
+			name: "no overlapping shifts",
+			tt: WeekTimetable{Monday: DayTimetable{Shifts: []ShiftTimetable{
+				shift("09:00", "17:00", 1),
+				shift("12:00", "20:00", 1),
+			}}},
+			ava:      map[string]store.WeekAvailability{"a@x.se": available},
+			want:     map[time.Weekday][][]string{time.Monday: {{"a@x.se"}, {}}},
+			unfilled: []string{"not enough available employees"},
+		},
+		{
+			name: "working-time rules",
+			tt: WeekTimetable{
+				Monday:  DayTimetable{Shifts: []ShiftTimetable{shift("14:00", "22:00", 1)}},
+				Tuesday: DayTimetable{Shifts: []ShiftTimetable{shift("06:00", "14:00", 1)}},
+			},
+			ava:     map[string]store.WeekAvailability{"a@x.se": available},
+			profile: RuleProfile{MinDailyRestHours: 11},
+			want: map[time.Weekday][][]string{
+				time.Monday:  {{"a@x.se"}},
+				time.Tuesday: {{}},
+			},
+			unfilled: []string{"remaining available employees would break working-time rules"},
+		},
+		{
+			name: "overnight shift needs the next day",
+			tt:   WeekTimetable{Monday: DayTimetable{Shifts: []ShiftTimetable{shift("22:00", "06:00", 1)}}},
+			ava: map[string]store.WeekAvailability{
+				"a@x.se": week(day(store.AvailabilityAvailable), map[time.Weekday]store.DayAvilability{
+					time.Tuesday: day(store.AvailabilityUnavailable),
+				}),
+				"b@x.se": week(day(store.AvailabilityAvailable), map[time.Weekday]store.DayAvilability{
+					time.Monday:  day(store.AvailabilityPartial, window("18:00", "00:00", store.LevelAvailable)),
+					time.Tuesday: day(store.AvailabilityPartial, window("00:00", "08:00", store.LevelAvailable)),
+				}),
+			},
+			want: map[time.Weekday][][]string{time.Monday: {{"b@x.se"}}},
+		},
+		{
+			name: "overnight Sunday shift needs the next Monday",
+			tt:   WeekTimetable{Sunday: DayTimetable{Shifts: []ShiftTimetable{shift("22:00", "06:00", 2)}}},
+			ava: map[string]store.WeekAvailability{
+				"a@x.se": nextMonday(available, day(store.AvailabilityUnavailable)),
+				"b@x.se": available,
+				"c@x.se": nextMonday(available, day(store.AvailabilityPartial, window("00:00", "06:00", store.LevelAvailable))),
+			},
+			want:     map[time.Weekday][][]string{time.Sunday: {{"c@x.se"}}},
+			unfilled: []string{"not enough available employees"},
+		},
+		{
+			name: "shift ending at midnight",
+			tt:   WeekTimetable{Sunday: DayTimetable{Shifts: []ShiftTimetable{shift("16:00", "00:00", 1)}}},
+			ava: map[string]store.WeekAvailability{
+				"a@x.se": nextMonday(available, day(store.AvailabilityUnavailable)),
+			},
+			want: map[time.Weekday][][]string{time.Sunday: {{"a@x.se"}}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			res := GenerateSchedule(testWeek, tc.tt, tc.employees, tc.ava, ComplianceContext{Profile: tc.profile})
+
+			for _, weekday := range Weekdays {
+				shifts := res.Schedule.Day(weekday).Shifts
+				want := tc.want[weekday]
+				if len(shifts) != len(want) {
+					t.Fatalf("%s: got %d shifts, want %d", weekday, len(shifts), len(want))
+				}
+				for i, ss := range shifts {
+					if !slices.Equal(ss.Employees, want[i]) {
+						t.Errorf("%s shift %d: got %v, want %v", weekday, i, ss.Employees, want[i])
+					}
+				}
+			}
+			for role, want := range tc.wantRole {
+				if got := res.Schedule.Monday.Shifts[0].Roles[role]; !slices.Equal(got, want) {
+					t.Errorf("role %s: got %v, want %v", role, got, want)
+				}
+			}
+
+			var reasons []string
+			for _, u := range res.Unfilled {
+				reasons = append(reasons, u.Reason)
+			}
+			if !slices.Equal(reasons, tc.unfilled) {
+				t.Errorf("unfilled: got %v, want %v", reasons, tc.unfilled)
+			}
+		})
+	}
+}
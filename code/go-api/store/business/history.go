@@ -0,0 +1,460 @@
+package business
+
+import (
+	"airdock/store"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+const (
+	VersionCreated  = "created"
+	VersionRestored = "restored"
+	// VersionImported is the version recorded for a schedule written without
+	// a version, either before history was kept or by a write that failed
+	// before recording its version.
+	VersionImported = "imported"
+)
+
+const (
+	ChangeShiftAdded      = "shift_added"
+	ChangeShiftRemoved    = "shift_removed"
+	ChangeEmployeeAdded   = "employee_added"
+	ChangeEmployeeRemoved = "employee_removed"
+	ChangeRoleAdded       = "role_added"
+	ChangeRoleRemoved     = "role_removed"
+)
+
+var (
+	ErrVersionNotFound = gocb.ErrDocumentNotFound
+)
+
+// ScheduleVersion is the schedule of a week as it was written at one point,
+// along with who wrote it and what changed compared to the version before.
+type ScheduleVersion struct {
+	Week      string    `json:"week"`
+	Version   int       `json:"version"`
+	Action    string    `json:"action"`
+	Actor     string    `json:"actor"`
+	CreatedAt time.Time `json:"createdAt"`
+	// RestoredFrom is the version that was restored by this version.
+	RestoredFrom int `json:"restoredFrom,omitempty"`
+	// Reference is the ID of the request that caused the version, e.g. a
+	// swap request.
+	Reference string           `json:"reference,omitempty"`
+	Changes   []ScheduleChange `json:"changes"`
+	Schedule  WeekSchedule     `json:"schedule"`
+}
+
+// ScheduleChange is a single difference between two schedules of a week.
+// Employee is empty for shifts added or removed as a whole and Role is only
+// set for role changes.
+type ScheduleChange struct {
+	Change   string      `json:"change"`
+	Date     string      `json:"date"`
+	Weekday  string      `json:"weekday"`
+	From     store.Clock `json:"from"`
+	To       store.Clock `json:"to"`
+	Employee string      `json:"employee,omitempty"`
+	Role     string      `json:"role,omitempty"`
+}
+
+func versionKey(week string, version int) string {
+	return fmt.Sprintf("%s::%d", week, version)
+}
+
+func counterKey(week string) string {
+	return week + "::counter"
+}
+
+// CreateScheduleForWeek stores the schedule of the week containing week and
+// records it as a new version written by actor. Weeks are keyed by the date
+// of their Monday.
+func (bs *BusinessStore) CreateScheduleForWeek(ctx context.Context, week time.Time, ws WeekSchedule, actor string) (ScheduleVersion, error) {
+	return bs.writeSchedule(ctx, week, ws, ScheduleVersion{
+		Action: VersionCreated,
+		Actor:  actor,
+	})
+}
+
+// RestoreScheduleVersion makes an earlier version the current schedule of the
+// week again. The restore is recorded as a new version.
+func (bs *BusinessStore) RestoreScheduleVersion(ctx context.Context, week time.Time, version int, actor string) (ScheduleVersion, error) {
+	old, err := bs.GetScheduleVersion(ctx, week, version)
+	if err != nil {
+		return ScheduleVersion{}, err
+	}
+
+	return bs.writeSchedule(ctx, week, old.Schedule, ScheduleVersion{
+		Action:       VersionRestored,
+		Actor:        actor,
+		RestoredFrom: version,
+	})
+}
+
+// writeSchedule stores ws as the schedule of the week and records it as a new
+// version.
+func (bs *BusinessStore) writeSchedule(ctx context.Context, week time.Time, ws WeekSchedule, sv ScheduleVersion) (ScheduleVersion, error) {
+	return bs.updateSchedule(ctx, week, sv, func(WeekSchedule) (WeekSchedule, error) {
+		return ws, nil
+	})
+}
+
+// updateSchedule applies update to the current schedule of the week with
+// optimistic locking and records the result as a new version. A schedule
+// left without a version by an earlier failure is recorded as imported first.
+func (bs *BusinessStore) updateSchedule(
+	ctx context.Context,
+	week time.Time,
+	sv ScheduleVersion,
+	update func(prev WeekSchedule) (WeekSchedule, error),
+) (ScheduleVersion, error) {
+	weekStart := store.StartOfWeek(week)
+	weekStr := weekStart.Format(time.DateOnly)
+
+	var prev, ws WeekSchedule
+	var status ScheduleStatus
+	var hasPrev bool
+	for attempt := 1; ; attempt++ {
+		var cas gocb.Cas
+		var err error
+		prev, cas, err = bs.getScheduleForUpdate(ctx, week)
+		hasPrev = err == nil
+		if err != nil && !errors.Is(err, ErrConfigNotFound) {
+			return ScheduleVersion{}, err
+		}
+
+		// the status is read after the schedule: publishing or locking the
+		// week rewrites the schedule afterwards, which fails the write below
+		status, err = bs.GetScheduleStatus(ctx, weekStart)
+		if err != nil {
+			return ScheduleVersion{}, err
+		}
+		if status.Status == StatusLocked {
+			return ScheduleVersion{}, ErrScheduleLocked
+		}
+
+		ws, err = update(prev)
+		if err != nil {
+			return ScheduleVersion{}, err
+		}
+
+		err = bs.putSchedule(ctx, weekStr, ws, cas)
+		concurrent := errors.Is(err, gocb.ErrCasMismatch) || errors.Is(err, gocb.ErrDocumentExists)
+		if concurrent && attempt < maxUpdateAttempts {
+			continue
+		}
+		if err != nil {
+			return ScheduleVersion{}, err
+		}
+		break
+	}
+
+	if hasPrev {
+		recorded, ok, err := bs.latestVersion(ctx, weekStr)
+		if err != nil {
+			return ScheduleVersion{}, err
+		}
+		if !ok || len(DiffSchedules(weekStart, recorded.Schedule, prev)) > 0 {
+			// keep the schedule written without a version, so it can be
+			// restored as well
+			version, err := bs.nextVersion(ctx, weekStr)
+			if err != nil {
+				return ScheduleVersion{}, err
+			}
+			err = bs.putVersion(ctx, ScheduleVersion{
+				Week:      weekStr,
+				Version:   version,
+				Action:    VersionImported,
+				CreatedAt: time.Now().In(bs.Location()),
+				Changes:   DiffSchedules(weekStart, recorded.Schedule, prev),
+				Schedule:  prev,
+			})
+			if err != nil {
+				return ScheduleVersion{}, err
+			}
+		}
+	}
+
+	version, err := bs.nextVersion(ctx, weekStr)
+	if err != nil {
+		return ScheduleVersion{}, err
+	}
+
+	sv.Week = weekStr
+	sv.Version = version
+	sv.CreatedAt = time.Now().In(bs.Location())
+	sv.Changes = DiffSchedules(weekStart, prev, ws)
+	sv.Schedule = ws
+	err = bs.putVersion(ctx, sv)
+	if err != nil {
+		return ScheduleVersion{}, err
+	}
+
+	if status.Status == StatusPublished {
+		err = bs.amend(ctx, weekStart, sv)
+		if err != nil {
+			return ScheduleVersion{}, err
+		}
+	}
+	return sv, nil
+}
+
+// putSchedule stores ws under the Monday of the week, inserting it if cas is
+// zero and replacing it if it has not changed since it was read otherwise.
+func (bs *BusinessStore) putSchedule(ctx context.Context, week string, ws WeekSchedule, cas gocb.Cas) error {
+	var err error
+	if cas == 0 {
+		_, err = bs.scheduleCol.Insert(week, ws, &gocb.InsertOptions{
+			Context: ctx,
+		})
+	} else {
+		_, err = bs.scheduleCol.Replace(week, ws, &gocb.ReplaceOptions{
+			Context: ctx,
+			Cas:     cas,
+		})
+	}
+	return err
+}
+
+// touchSchedule rewrites the schedule of the week unchanged, so that writes
+// of the schedule that started before a status change fail and are retried
+// with the new status.
+func (bs *BusinessStore) touchSchedule(ctx context.Context, week time.Time) error {
+	weekStr := store.StartOfWeek(week).Format(time.DateOnly)
+	for attempt := 1; ; attempt++ {
+		ws, cas, err := bs.getScheduleForUpdate(ctx, week)
+		if errors.Is(err, ErrConfigNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		err = bs.putSchedule(ctx, weekStr, ws, cas)
+		concurrent := errors.Is(err, gocb.ErrCasMismatch) || errors.Is(err, gocb.ErrDocumentExists)
+		if concurrent && attempt < maxUpdateAttempts {
+			continue
+		}
+		return err
+	}
+}
+
+const maxUpdateAttempts = 5
+
+// getScheduleForUpdate returns the schedule of the week and the CAS to
+// replace it with. The CAS is zero if the schedule is not stored under the
+// Monday of the week yet.
+func (bs *BusinessStore) getScheduleForUpdate(ctx context.Context, week time.Time) (WeekSchedule, gocb.Cas, error) {
+	res, err := bs.scheduleCol.Get(store.StartOfWeek(week).Format(time.DateOnly), &gocb.GetOptions{
+		Context: ctx,
+	})
+	if errors.Is(err, gocb.ErrDocumentNotFound) {
+		ws, err := bs.GetScheduleForWeek(ctx, week)
+		return ws, 0, err
+	}
+	if err != nil {
+		return WeekSchedule{}, 0, err
+	}
+
+	var ws WeekSchedule
+	err = res.Content(&ws)
+	return ws, res.Cas(), err
+}
+
+func (bs *BusinessStore) nextVersion(ctx context.Context, week string) (int, error) {
+	res, err := bs.historyCol.Binary().Increment(counterKey(week), &gocb.IncrementOptions{
+		Context: ctx,
+		Initial: 1,
+		Delta:   1,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int(res.Content()), nil
+}
+
+// latestVersion returns the version with the highest number handed out for
+// the week. It reports false if there is none or it was never stored.
+func (bs *BusinessStore) latestVersion(ctx context.Context, week string) (ScheduleVersion, bool, error) {
+	res, err := bs.historyCol.Get(counterKey(week), &gocb.GetOptions{
+		Context: ctx,
+	})
+	if errors.Is(err, gocb.ErrDocumentNotFound) {
+		return ScheduleVersion{}, false, nil
+	}
+	if err != nil {
+		return ScheduleVersion{}, false, err
+	}
+	var version int
+	err = res.Content(&version)
+	if err != nil {
+		return ScheduleVersion{}, false, err
+	}
+
+	res, err = bs.historyCol.Get(versionKey(week, version), &gocb.GetOptions{
+		Context: ctx,
+	})
+	if errors.Is(err, gocb.ErrDocumentNotFound) {
+		return ScheduleVersion{}, false, nil
+	}
+	if err != nil {
+		return ScheduleVersion{}, false, err
+	}
+	var sv ScheduleVersion
+	err = res.Content(&sv)
+	return sv, err == nil, err
+}
+
+func (bs *BusinessStore) putVersion(ctx context.Context, sv ScheduleVersion) error {
+	_, err := bs.historyCol.Insert(versionKey(sv.Week, sv.Version), sv, &gocb.InsertOptions{
+		Context: ctx,
+	})
+	return err
+}
+
+func (bs *BusinessStore) GetScheduleVersion(ctx context.Context, week time.Time, version int) (ScheduleVersion, error) {
+	weekStr := store.StartOfWeek(week).Format(time.DateOnly)
+	res, err := bs.historyCol.Get(versionKey(weekStr, version), &gocb.GetOptions{
+		Context: ctx,
+	})
+	if err != nil {
+		return ScheduleVersion{}, err
+	}
+
+	var sv ScheduleVersion
+	err = res.Content(&sv)
+	return sv, err
+}
+
+// ScheduleVersions returns all versions of the schedule of a week, oldest
+// first.
+func (bs *BusinessStore) ScheduleVersions(ctx context.Context, week time.Time) ([]ScheduleVersion, error) {
+	res, err := bs.scope.Query(
+		"SELECT x.* FROM schedule_history x WHERE x.week = $week ORDER BY x.version",
+		&gocb.QueryOptions{
+			Context:         ctx,
+			ScanConsistency: gocb.QueryScanConsistencyRequestPlus,
+			NamedParameters: map[string]interface{}{
+				"week": store.StartOfWeek(week).Format(time.DateOnly),
+			},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close() // ignore error
+
+	versions := []ScheduleVersion{}
+	for res.Next() {
+		var sv ScheduleVersion
+		err := res.Row(&sv)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, sv)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+	}
+
+	return versions, res.Err()
+}
+
+// referencedVersion returns the version of the schedule of the week that
+// action on the request with the given ID was recorded in, so that applying
+// a request twice does not change the schedule twice.
+func (bs *BusinessStore) referencedVersion(ctx context.Context, week time.Time, action string, reference string) (ScheduleVersion, error) {
+	versions, err := bs.ScheduleVersions(ctx, week)
+	if err != nil {
+		return ScheduleVersion{}, err
+	}
+	for _, sv := range versions {
+		if sv.Action == action && sv.Reference == reference {
+			return sv, nil
+		}
+	}
+	return ScheduleVersion{}, ErrVersionNotFound
+}
+
+// DiffSchedules lists the changes from schedule a to schedule b of the week
+// containing week. Shifts are matched by day and time.
+func DiffSchedules(week time.Time, a WeekSchedule, b WeekSchedule) []ScheduleChange {
+	weekStart := store.StartOfWeek(week)
+	changes := []ScheduleChange{}
+	for dayIdx, weekday := range Weekdays {
+		date := weekStart.AddDate(0, 0, dayIdx).Format(time.DateOnly)
+		newChange := func(change string, s ShiftSchedule, employee string, role string) ScheduleChange {
+			return ScheduleChange{
+				Change:   change,
+				Date:     date,
+				Weekday:  weekday.String(),
+				From:     s.From,
+				To:       s.To,
+				Employee: employee,
+				Role:     role,
+			}
+		}
+
+		before, after := a.Day(weekday).Shifts, b.Day(weekday).Shifts
+		for _, s := range before {
+			if _, ok := findScheduledShift(after, s.From, s.To); !ok {
+				changes = append(changes, newChange(ChangeShiftRemoved, s, "", ""))
+			}
+		}
+		for _, s := range after {
+			old, ok := findScheduledShift(before, s.From, s.To)
+			if !ok {
+				changes = append(changes, newChange(ChangeShiftAdded, s, "", ""))
+			}
+
+			for _, email := range old.Employees {
+				if !slices.Contains(s.Employees, email) {
+					changes = append(changes, newChange(ChangeEmployeeRemoved, s, email, ""))
+				}
+			}
+			for _, email := range s.Employees {
+				if !slices.Contains(old.Employees, email) {
+					changes = append(changes, newChange(ChangeEmployeeAdded, s, email, ""))
+				}
+			}
+
+			roles := make(map[string]bool)
+			for role := range old.Roles {
+				roles[role] = true
+			}
+			for role := range s.Roles {
+				roles[role] = true
+			}
+			for _, role := range sortedKeys(roles) {
+				for _, email := range old.Roles[role] {
+					if !slices.Contains(s.Roles[role], email) {
+						changes = append(changes, newChange(ChangeRoleRemoved, s, email, role))
+					}
+				}
+				for _, email := range s.Roles[role] {
+					if !slices.Contains(old.Roles[role], email) {
+						changes = append(changes, newChange(ChangeRoleAdded, s, email, role))
+					}
+				}
+			}
+		}
+	}
+	return changes
+}
+
+func findScheduledShift(shifts []ShiftSchedule, from store.Clock, to store.Clock) (ShiftSchedule, bool) {
+	for _, s := range shifts {
+		if s.From == from && s.To == to {
+			return s, true
+		}
+	}
+	return ShiftSchedule{}, false
+}
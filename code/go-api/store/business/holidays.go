@@ -0,0 +1,260 @@
+package business
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+const (
+	HolidaySettingsKey = "holiday-settings"
+)
+
+const (
+	CountrySweden  = "SE"
+	CountryNorway  = "NO"
+	CountryDenmark = "DK"
+	CountryFinland = "FI"
+)
+
+// Countries lists the countries with a holiday calendar.
+var Countries = []string{CountrySweden, CountryNorway, CountryDenmark, CountryFinland}
+
+const (
+	// HolidayOpen uses the regular timetable on the holiday.
+	HolidayOpen = "open"
+	// HolidayClosed has no shifts on the holiday.
+	HolidayClosed = "closed"
+	// HolidaySunday uses the Sunday timetable of the week on the holiday.
+	HolidaySunday = "sunday"
+)
+
+// Holiday is a public holiday, or a day that is observed as one such as
+// Christmas Eve in Sweden. ID identifies the holiday across years.
+type Holiday struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Date string `json:"date"`
+}
+
+type holidayRule struct {
+	id   string
+	name string
+	date func(year int) time.Time
+}
+
+func fixed(month time.Month, day int) func(year int) time.Time {
+	return func(year int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+func fromEaster(days int) func(year int) time.Time {
+	return func(year int) time.Time {
+		return easter(year).AddDate(0, 0, days)
+	}
+}
+
+// firstWeekday returns the first given weekday on or after month and day.
+func firstWeekday(month time.Month, day int, weekday time.Weekday) func(year int) time.Time {
+	return func(year int) time.Time {
+		d := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+		return d.AddDate(0, 0, (int(weekday)-int(d.Weekday())+7)%7)
+	}
+}
+
+// until limits a holiday to the years up to and including lastYear.
+func until(lastYear int, date func(year int) time.Time) func(year int) time.Time {
+	return func(year int) time.Time {
+		if year > lastYear {
+			return time.Time{}
+		}
+		return date(year)
+	}
+}
+
+// easter returns Easter Sunday of the Gregorian calendar using the anonymous
+// Gregorian algorithm.
+func easter(year int) time.Time {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
+
+var holidayCalendars = map[string][]holidayRule{
+	CountrySweden: {
+		{"new-years-day", "Nyårsdagen", fixed(time.January, 1)},
+		{"epiphany", "Trettondedag jul", fixed(time.January, 6)},
+		{"good-friday", "Långfredagen", fromEaster(-2)},
+		{"easter-sunday", "Påskdagen", fromEaster(0)},
+		{"easter-monday", "Annandag påsk", fromEaster(1)},
+		{"may-day", "Första maj", fixed(time.May, 1)},
+		{"ascension-day", "Kristi himmelsfärdsdag", fromEaster(39)},
+		{"whit-sunday", "Pingstdagen", fromEaster(49)},
+		{"national-day", "Sveriges nationaldag", fixed(time.June, 6)},
+		{"midsummer-eve", "Midsommarafton", firstWeekday(time.June, 19, time.Friday)},
+		{"midsummer-day", "Midsommardagen", firstWeekday(time.June, 20, time.Saturday)},
+		{"all-saints-day", "Alla helgons dag", firstWeekday(time.October, 31, time.Saturday)},
+		{"christmas-eve", "Julafton", fixed(time.December, 24)},
+		{"christmas-day", "Juldagen", fixed(time.December, 25)},
+		{"boxing-day", "Annandag jul", fixed(time.December, 26)},
+		{"new-years-eve", "Nyårsafton", fixed(time.December, 31)},
+	},
+	CountryNorway: {
+		{"new-years-day", "Første nyttårsdag", fixed(time.January, 1)},
+		{"maundy-thursday", "Skjærtorsdag", fromEaster(-3)},
+		{"good-friday", "Langfredag", fromEaster(-2)},
+		{"easter-sunday", "Første påskedag", fromEaster(0)},
+		{"easter-monday", "Andre påskedag", fromEaster(1)},
+		{"may-day", "Arbeidernes dag", fixed(time.May, 1)},
+		{"constitution-day", "Grunnlovsdag", fixed(time.May, 17)},
+		{"ascension-day", "Kristi himmelfartsdag", fromEaster(39)},
+		{"whit-sunday", "Første pinsedag", fromEaster(49)},
+		{"whit-monday", "Andre pinsedag", fromEaster(50)},
+		{"christmas-eve", "Julaften", fixed(time.December, 24)},
+		{"christmas-day", "Første juledag", fixed(time.December, 25)},
+		{"boxing-day", "Andre juledag", fixed(time.December, 26)},
+	},
+	CountryDenmark: {
+		{"new-years-day", "Nytårsdag", fixed(time.January, 1)},
+		{"maundy-thursday", "Skærtorsdag", fromEaster(-3)},
+		{"good-friday", "Langfredag", fromEaster(-2)},
+		{"easter-sunday", "Påskedag", fromEaster(0)},
+		{"easter-monday", "2. påskedag", fromEaster(1)},
+		{"great-prayer-day", "Store bededag", until(2023, fromEaster(26))},
+		{"ascension-day", "Kristi himmelfartsdag", fromEaster(39)},
+		{"whit-sunday", "Pinsedag", fromEaster(49)},
+		{"whit-monday", "2. pinsedag", fromEaster(50)},
+		{"constitution-day", "Grundlovsdag", fixed(time.June, 5)},
+		{"christmas-eve", "Juleaftensdag", fixed(time.December, 24)},
+		{"christmas-day", "Juledag", fixed(time.December, 25)},
+		{"boxing-day", "2. juledag", fixed(time.December, 26)},
+	},
+	CountryFinland: {
+		{"new-years-day", "Uudenvuodenpäivä", fixed(time.January, 1)},
+		{"epiphany", "Loppiainen", fixed(time.January, 6)},
+		{"good-friday", "Pitkäperjantai", fromEaster(-2)},
+		{"easter-sunday", "Pääsiäispäivä", fromEaster(0)},
+		{"easter-monday", "Toinen pääsiäispäivä", fromEaster(1)},
+		{"may-day", "Vappu", fixed(time.May, 1)},
+		{"ascension-day", "Helatorstai", fromEaster(39)},
+		{"whit-sunday", "Helluntaipäivä", fromEaster(49)},
+		{"midsummer-eve", "Juhannusaatto", firstWeekday(time.June, 19, time.Friday)},
+		{"midsummer-day", "Juhannuspäivä", firstWeekday(time.June, 20, time.Saturday)},
+		{"all-saints-day", "Pyhäinpäivä", firstWeekday(time.October, 31, time.Saturday)},
+		{"independence-day", "Itsenäisyyspäivä", fixed(time.December, 6)},
+		{"christmas-eve", "Jouluaatto", fixed(time.December, 24)},
+		{"christmas-day", "Joulupäivä", fixed(time.December, 25)},
+		{"boxing-day", "Tapaninpäivä", fixed(time.December, 26)},
+	},
+}
+
+// Holidays returns the holidays of country between from and to, both
+// inclusive, ordered by date.
+func Holidays(country string, from time.Time, to time.Time) ([]Holiday, error) {
+	rules, ok := holidayCalendars[country]
+	if !ok {
+		return nil, fmt.Errorf("no holiday calendar for country %q", country)
+	}
+
+	fromStr, toStr := from.Format(time.DateOnly), to.Format(time.DateOnly)
+	holidays := []Holiday{}
+	for year := from.Year(); year <= to.Year(); year++ {
+		for _, r := range rules {
+			d := r.date(year)
+			if d.IsZero() {
+				continue
+			}
+			date := d.Format(time.DateOnly)
+			// dates in this format sort chronologically as strings
+			if date < fromStr || date > toStr {
+				continue
+			}
+			holidays = append(holidays, Holiday{
+				ID:   r.id,
+				Name: r.name,
+				Date: date,
+			})
+		}
+	}
+
+	sort.SliceStable(holidays, func(i, j int) bool {
+		return holidays[i].Date < holidays[j].Date
+	})
+	return holidays, nil
+}
+
+// HolidaySettings decides how the timetable is affected by the holidays of
+// Country. Rules overrides Default for single holidays, keyed by holiday ID.
+// An empty Country disables holidays.
+type HolidaySettings struct {
+	Country string            `json:"country"`
+	Default string            `json:"default"`
+	Rules   map[string]string `json:"rules"`
+}
+
+func defaultHolidaySettings() HolidaySettings {
+	return HolidaySettings{
+		Default: HolidayOpen,
+		Rules:   map[string]string{},
+	}
+}
+
+func (hs HolidaySettings) rule(h Holiday) string {
+	if r, ok := hs.Rules[h.ID]; ok {
+		return r
+	}
+	return hs.Default
+}
+
+func (bs *BusinessStore) GetHolidaySettings(ctx context.Context) (HolidaySettings, error) {
+	res, err := bs.configCol.Get(HolidaySettingsKey, &gocb.GetOptions{
+		Context: ctx,
+	})
+	if errors.Is(err, gocb.ErrDocumentNotFound) {
+		return defaultHolidaySettings(), nil
+	}
+	if err != nil {
+		return HolidaySettings{}, err
+	}
+
+	var hs HolidaySettings
+	err = res.Content(&hs)
+	return hs, err
+}
+
+func (bs *BusinessStore) SetHolidaySettings(ctx context.Context, hs HolidaySettings) error {
+	if _, ok := holidayCalendars[hs.Country]; !ok && hs.Country != "" {
+		return fmt.Errorf("no holiday calendar for country %q", hs.Country)
+	}
+	return bs.SetConfig(ctx, HolidaySettingsKey, hs)
+}
+
+// GetHolidays returns the holidays of the configured country between from and
+// to, both inclusive. No holidays are returned if no country is configured.
+func (bs *BusinessStore) GetHolidays(ctx context.Context, from time.Time, to time.Time) ([]Holiday, error) {
+	hs, err := bs.GetHolidaySettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if hs.Country == "" {
+		return []Holiday{}, nil
+	}
+	return Holidays(hs.Country, from, to)
+}
@@ -0,0 +1,167 @@
+package business
+
+import (
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	d, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func TestEaster(t *testing.T) {
+	tests := []struct {
+		year int
+		want string
+	}{
+		{1818, "1818-03-22"},
+		{2000, "2000-04-23"},
+		{2019, "2019-04-21"},
+		{2024, "2024-03-31"},
+		{2025, "2025-04-20"},
+		{2026, "2026-04-05"},
+		{2038, "2038-04-25"},
+	}
+
+	for _, tc := range tests {
+		if got := easter(tc.year).Format(time.DateOnly); got != tc.want {
+			t.Errorf("easter(%d): got %s, want %s", tc.year, got, tc.want)
+		}
+	}
+}
+
+func TestHolidays(t *testing.T) {
+	tests := []struct {
+		name    string
+		country string
+		from    string
+		to      string
+		want    map[string]string
+		// absent are holiday IDs that must not be in the range
+		absent []string
+	}{
+		{
+			name:    "swedish midsummer on a friday",
+			country: CountrySweden,
+			from:    "2026-06-01",
+			to:      "2026-06-30",
+			want: map[string]string{
+				"national-day":  "2026-06-06",
+				"midsummer-eve": "2026-06-19",
+				"midsummer-day": "2026-06-20",
+			},
+		},
+		{
+			name:    "swedish midsummer late in the window",
+			country: CountrySweden,
+			from:    "2024-06-01",
+			to:      "2024-06-30",
+			want: map[string]string{
+				"midsummer-eve": "2024-06-21",
+				"midsummer-day": "2024-06-22",
+			},
+		},
+		{
+			name:    "finnish midsummer",
+			country: CountryFinland,
+			from:    "2025-06-01",
+			to:      "2025-06-30",
+			want: map[string]string{
+				"midsummer-eve": "2025-06-20",
+				"midsummer-day": "2025-06-21",
+			},
+		},
+		{
+			name:    "all saints day on the last day of october",
+			country: CountrySweden,
+			from:    "2026-10-01",
+			to:      "2026-11-30",
+			want:    map[string]string{"all-saints-day": "2026-10-31"},
+		},
+		{
+			name:    "all saints day in november",
+			country: CountrySweden,
+			from:    "2025-10-01",
+			to:      "2025-11-30",
+			want:    map[string]string{"all-saints-day": "2025-11-01"},
+		},
+		{
+			name:    "holidays relative to easter",
+			country: CountryNorway,
+			from:    "2026-03-01",
+			to:      "2026-05-31",
+			want: map[string]string{
+				"maundy-thursday":  "2026-04-02",
+				"good-friday":      "2026-04-03",
+				"easter-sunday":    "2026-04-05",
+				"easter-monday":    "2026-04-06",
+				"constitution-day": "2026-05-17",
+				"ascension-day":    "2026-05-14",
+				"whit-sunday":      "2026-05-24",
+				"whit-monday":      "2026-05-25",
+			},
+		},
+		{
+			name:    "great prayer day until 2023",
+			country: CountryDenmark,
+			from:    "2023-05-01",
+			to:      "2023-05-31",
+			want:    map[string]string{"great-prayer-day": "2023-05-05"},
+		},
+		{
+			name:    "great prayer day abolished",
+			country: CountryDenmark,
+			from:    "2024-04-01",
+			to:      "2024-05-31",
+			absent:  []string{"great-prayer-day"},
+		},
+		{
+			name:    "range across new year",
+			country: CountrySweden,
+			from:    "2025-12-31",
+			to:      "2026-01-01",
+			want: map[string]string{
+				"new-years-eve": "2025-12-31",
+				"new-years-day": "2026-01-01",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			holidays, err := Holidays(tc.country, date(tc.from), date(tc.to))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make(map[string]string, len(holidays))
+			for i, h := range holidays {
+				if i > 0 && h.Date < holidays[i-1].Date {
+					t.Errorf("%s is listed after %s", h.Date, holidays[i-1].Date)
+				}
+				got[h.ID] = h.Date
+			}
+			for id, want := range tc.want {
+				if got[id] != want {
+					t.Errorf("%s: got %q, want %q", id, got[id], want)
+				}
+			}
+			for _, id := range tc.absent {
+				if d, ok := got[id]; ok {
+					t.Errorf("%s: got %s, want none", id, d)
+				}
+			}
+		})
+	}
+}
+
+func TestHolidaysUnknownCountry(t *testing.T) {
+	_, err := Holidays("XX", date("2026-01-01"), date("2026-12-31"))
+	if err == nil {
+		t.Error("got no error for an unknown country")
+	}
+}
@@ -0,0 +1,86 @@
+package business
+
+import (
+	"airdock/store"
+	"context"
+	"errors"
+	"slices"
+	"time"
+)
+
+// LeaveConflict is a shift the employee on leave works in a published or
+// locked schedule.
+type LeaveConflict struct {
+	Week    string      `json:"week"`
+	Date    string      `json:"date"`
+	Weekday string      `json:"weekday"`
+	From    store.Clock `json:"from"`
+	To      store.Clock `json:"to"`
+	// Status is the status of the schedule the shift is in.
+	Status string `json:"status"`
+}
+
+// LeaveConflicts returns the shifts of published and locked schedules that
+// the employee of lr works during the leave. Drafts are left out, as the
+// leave shows up as unavailability when they are validated.
+func (bs *BusinessStore) LeaveConflicts(ctx context.Context, lr store.LeaveRequest) ([]LeaveConflict, error) {
+	loc := bs.Location()
+	first, err := time.ParseInLocation(time.DateOnly, lr.From, loc)
+	if err != nil {
+		return nil, err
+	}
+	last, err := time.ParseInLocation(time.DateOnly, lr.To, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	var leave []workShift
+	for date := first; !date.After(last); date = date.AddDate(0, 0, 1) {
+		start, end := lr.Interval(date)
+		leave = append(leave, workShift{start: start, end: end})
+	}
+
+	conflicts := []LeaveConflict{}
+	// overnight shifts of the day before the leave may reach into it
+	for week := store.StartOfWeek(first.AddDate(0, 0, -1)); !week.After(last); week = week.AddDate(0, 0, 7) {
+		status, err := bs.GetScheduleStatus(ctx, week)
+		if err != nil {
+			return nil, err
+		}
+		if !status.Visible() {
+			continue
+		}
+
+		ws, err := bs.GetScheduleForWeek(ctx, week)
+		if errors.Is(err, ErrConfigNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for dayIdx, weekday := range Weekdays {
+			date := week.AddDate(0, 0, dayIdx)
+			for _, s := range ws.Day(weekday).Shifts {
+				if !slices.Contains(s.Employees, lr.Employee) {
+					continue
+				}
+
+				start, end := shiftInterval(date, s.From, s.To)
+				work := workShift{start: start, end: end}
+				if !slices.ContainsFunc(leave, func(l workShift) bool { return overlaps(l, work) }) {
+					continue
+				}
+				conflicts = append(conflicts, LeaveConflict{
+					Week:    status.Week,
+					Date:    date.Format(time.DateOnly),
+					Weekday: weekday.String(),
+					From:    s.From,
+					To:      s.To,
+					Status:  status.Status,
+				})
+			}
+		}
+	}
+	return conflicts, nil
+}
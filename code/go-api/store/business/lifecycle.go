@@ -0,0 +1,183 @@
+package business
+
+import (
+	"airdock/store"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+const (
+	StatusDraft     = "draft"
+	StatusPublished = "published"
+	StatusLocked    = "locked"
+)
+
+var (
+	ErrScheduleLocked    = errors.New("schedule is locked")
+	ErrInvalidTransition = errors.New("invalid schedule status transition")
+)
+
+// Amendment is a change made to a schedule after it was published.
+type Amendment struct {
+	Version int              `json:"version"`
+	Actor   string           `json:"actor"`
+	At      time.Time        `json:"at"`
+	Changes []ScheduleChange `json:"changes"`
+}
+
+// ScheduleStatus is where the schedule of a week is in its lifecycle: draft,
+// published or locked. Weeks that have ended are always locked.
+type ScheduleStatus struct {
+	Week        string      `json:"week"`
+	Status      string      `json:"status"`
+	PublishedAt *time.Time  `json:"publishedAt,omitempty"`
+	PublishedBy string      `json:"publishedBy,omitempty"`
+	LockedAt    *time.Time  `json:"lockedAt,omitempty"`
+	LockedBy    string      `json:"lockedBy,omitempty"`
+	Amendments  []Amendment `json:"amendments"`
+}
+
+// Visible reports whether employees may see the schedule. Weeks that were
+// locked because they ended are only visible if they had been published.
+func (ss ScheduleStatus) Visible() bool {
+	return ss.Status == StatusPublished || (ss.Status == StatusLocked && ss.PublishedAt != nil)
+}
+
+func (bs *BusinessStore) GetScheduleStatus(ctx context.Context, week time.Time) (ScheduleStatus, error) {
+	ss, _, err := bs.getScheduleStatusForUpdate(ctx, week)
+	return ss, err
+}
+
+// getScheduleStatusForUpdate returns the status of the week and the CAS to
+// replace it with. The CAS is zero if no status is stored for the week yet.
+func (bs *BusinessStore) getScheduleStatusForUpdate(ctx context.Context, week time.Time) (ScheduleStatus, gocb.Cas, error) {
+	weekStart := store.StartOfWeek(week)
+	weekStr := weekStart.Format(time.DateOnly)
+
+	ss := ScheduleStatus{
+		Week:       weekStr,
+		Status:     StatusDraft,
+		Amendments: []Amendment{},
+	}
+	var cas gocb.Cas
+	res, err := bs.statusCol.Get(weekStr, &gocb.GetOptions{
+		Context: ctx,
+	})
+	if err != nil && !errors.Is(err, gocb.ErrDocumentNotFound) {
+		return ScheduleStatus{}, 0, err
+	}
+	if err == nil {
+		err = res.Content(&ss)
+		if err != nil {
+			return ScheduleStatus{}, 0, err
+		}
+		cas = res.Cas()
+	}
+
+	if !weekStart.AddDate(0, 0, 7).After(time.Now()) {
+		ss.Status = StatusLocked
+	}
+	return ss, cas, nil
+}
+
+// updateScheduleStatus applies update to the current status of the week and
+// stores the result with optimistic locking, calling update again with the
+// newer status if it changed in the meantime.
+func (bs *BusinessStore) updateScheduleStatus(
+	ctx context.Context,
+	week time.Time,
+	update func(ss ScheduleStatus) (ScheduleStatus, error),
+) (ScheduleStatus, error) {
+	for attempt := 1; ; attempt++ {
+		prev, cas, err := bs.getScheduleStatusForUpdate(ctx, week)
+		if err != nil {
+			return ScheduleStatus{}, err
+		}
+
+		ss, err := update(prev)
+		if err != nil {
+			return ScheduleStatus{}, err
+		}
+
+		if cas == 0 {
+			_, err = bs.statusCol.Insert(ss.Week, ss, &gocb.InsertOptions{
+				Context: ctx,
+			})
+		} else {
+			_, err = bs.statusCol.Replace(ss.Week, ss, &gocb.ReplaceOptions{
+				Context: ctx,
+				Cas:     cas,
+			})
+		}
+		concurrent := errors.Is(err, gocb.ErrCasMismatch) || errors.Is(err, gocb.ErrDocumentExists)
+		if concurrent && attempt < maxUpdateAttempts {
+			continue
+		}
+		if err != nil {
+			return ScheduleStatus{}, err
+		}
+		return ss, nil
+	}
+}
+
+// PublishSchedule makes the draft schedule of a week visible to employees.
+func (bs *BusinessStore) PublishSchedule(ctx context.Context, week time.Time, actor string) (ScheduleStatus, error) {
+	_, err := bs.GetScheduleForWeek(ctx, week)
+	if err != nil {
+		return ScheduleStatus{}, err
+	}
+
+	ss, err := bs.updateScheduleStatus(ctx, week, func(ss ScheduleStatus) (ScheduleStatus, error) {
+		if ss.Status != StatusDraft {
+			return ScheduleStatus{}, fmt.Errorf("%w: cannot publish a %s schedule", ErrInvalidTransition, ss.Status)
+		}
+
+		now := time.Now().In(bs.Location())
+		ss.Status = StatusPublished
+		ss.PublishedAt = &now
+		ss.PublishedBy = actor
+		return ss, nil
+	})
+	if err != nil {
+		return ScheduleStatus{}, err
+	}
+	return ss, bs.touchSchedule(ctx, week)
+}
+
+// LockSchedule makes the published schedule of a week read-only.
+func (bs *BusinessStore) LockSchedule(ctx context.Context, week time.Time, actor string) (ScheduleStatus, error) {
+	ss, err := bs.updateScheduleStatus(ctx, week, func(ss ScheduleStatus) (ScheduleStatus, error) {
+		if ss.Status != StatusPublished {
+			return ScheduleStatus{}, fmt.Errorf("%w: cannot lock a %s schedule", ErrInvalidTransition, ss.Status)
+		}
+
+		now := time.Now().In(bs.Location())
+		ss.Status = StatusLocked
+		ss.LockedAt = &now
+		ss.LockedBy = actor
+		return ss, nil
+	})
+	if err != nil {
+		return ScheduleStatus{}, err
+	}
+	return ss, bs.touchSchedule(ctx, week)
+}
+
+// amend records a version written to a published schedule as an amendment,
+// keeping any status change made since the version was written.
+func (bs *BusinessStore) amend(ctx context.Context, week time.Time, sv ScheduleVersion) error {
+	_, err := bs.updateScheduleStatus(ctx, week, func(ss ScheduleStatus) (ScheduleStatus, error) {
+		ss.Amendments = append(ss.Amendments, Amendment{
+			Version: sv.Version,
+			Actor:   sv.Actor,
+			At:      sv.CreatedAt,
+			Changes: sv.Changes,
+		})
+		return ss, nil
+	})
+	return err
+}
@@ -0,0 +1,494 @@
+package business
+
+import (
+	"airdock/store"
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	ClaimPendingApproval = "pending_approval"
+	ClaimApplied         = "applied"
+	ClaimRejected        = "rejected"
+	ClaimCancelled       = "cancelled"
+)
+
+const (
+	VersionClaimed = "claimed"
+)
+
+var (
+	ErrClaimNotFound  = gocb.ErrDocumentNotFound
+	ErrClaimClosed    = errors.New("claim is no longer pending")
+	ErrClaimChanged   = errors.New("claim was changed at the same time, try again")
+	ErrNotPublished   = errors.New("schedule is not published")
+	ErrNoOpenPlace    = errors.New("shift has no open place")
+	ErrShiftStarted   = errors.New("shift has already started")
+	ErrAlreadyClaimed = errors.New("employee has already claimed the shift")
+)
+
+// OpenShift is a shift of a published schedule that has fewer employees than
+// the timetable requires.
+type OpenShift struct {
+	Week    string      `json:"week"`
+	Date    string      `json:"date"`
+	Weekday string      `json:"weekday"`
+	From    store.Clock `json:"from"`
+	To      store.Clock `json:"to"`
+	ShiftCoverage
+	// Claims are the claims on the shift waiting for a manager.
+	Claims []ShiftClaim `json:"claims"`
+}
+
+// ShiftClaim is an employee taking an open place on a shift, optionally to
+// work Role. Claims are applied right away unless the business requires a
+// manager to approve them.
+type ShiftClaim struct {
+	ID        string      `json:"id"`
+	Week      string      `json:"week"`
+	Date      string      `json:"date"`
+	From      store.Clock `json:"from"`
+	To        store.Clock `json:"to"`
+	Employee  string      `json:"employee"`
+	Role      string      `json:"role,omitempty"`
+	Status    string      `json:"status"`
+	CreatedAt time.Time   `json:"createdAt"`
+	DecidedBy string      `json:"decidedBy,omitempty"`
+	DecidedAt *time.Time  `json:"decidedAt,omitempty"`
+	// Version is the schedule version the claim was applied in.
+	Version int `json:"version,omitempty"`
+}
+
+func (c ShiftClaim) date(loc *time.Location) (time.Time, error) {
+	return time.ParseInLocation(time.DateOnly, c.Date, loc)
+}
+
+// OpenShifts returns the shifts of the published week containing week that
+// still have open places and have not started yet.
+func (bs *BusinessStore) OpenShifts(ctx context.Context, week time.Time) ([]OpenShift, error) {
+	weekStart := store.StartOfWeek(week)
+	status, err := bs.GetScheduleStatus(ctx, weekStart)
+	if err != nil {
+		return nil, err
+	}
+	if status.Status != StatusPublished {
+		return []OpenShift{}, nil
+	}
+
+	ws, err := bs.GetScheduleForWeek(ctx, weekStart)
+	if err != nil {
+		return nil, err
+	}
+	tt, err := bs.timetableForWeek(ctx, weekStart)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := bs.Claims(ctx, weekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	open := []OpenShift{}
+	for dayIdx, weekday := range Weekdays {
+		date := weekStart.AddDate(0, 0, dayIdx)
+		dateStr := date.Format(time.DateOnly)
+		for _, stt := range tt.Day(weekday).Shifts {
+			if start, _ := shiftInterval(date, stt.From, stt.To); !start.After(now) {
+				continue
+			}
+			ss, _ := findScheduledShift(ws.Day(weekday).Shifts, stt.From, stt.To)
+			cov := CoverShift(stt, ss)
+			if cov.Missing == 0 {
+				continue
+			}
+
+			os := OpenShift{
+				Week:          status.Week,
+				Date:          dateStr,
+				Weekday:       weekday.String(),
+				From:          stt.From,
+				To:            stt.To,
+				ShiftCoverage: cov,
+				Claims:        []ShiftClaim{},
+			}
+			for _, c := range claims {
+				if c.Status == ClaimPendingApproval && c.Date == dateStr && c.From == stt.From && c.To == stt.To {
+					os.Claims = append(os.Claims, c)
+				}
+			}
+			open = append(open, os)
+		}
+	}
+	return open, nil
+}
+
+func (bs *BusinessStore) GetClaim(ctx context.Context, id string) (ShiftClaim, error) {
+	c, _, err := bs.getClaimForUpdate(ctx, id)
+	return c, err
+}
+
+// getClaimForUpdate returns the claim and the CAS to replace it with.
+func (bs *BusinessStore) getClaimForUpdate(ctx context.Context, id string) (ShiftClaim, gocb.Cas, error) {
+	res, err := bs.claimCol.Get(id, &gocb.GetOptions{
+		Context: ctx,
+	})
+	if err != nil {
+		return ShiftClaim{}, 0, err
+	}
+
+	var c ShiftClaim
+	err = res.Content(&c)
+	return c, res.Cas(), err
+}
+
+// putClaim writes c if it has not changed since it was read with cas, and
+// returns ErrClaimChanged otherwise. A zero cas inserts a new claim.
+func (bs *BusinessStore) putClaim(ctx context.Context, c ShiftClaim, cas gocb.Cas) (gocb.Cas, error) {
+	var res *gocb.MutationResult
+	var err error
+	if cas == 0 {
+		res, err = bs.claimCol.Insert(c.ID, c, &gocb.InsertOptions{
+			Context: ctx,
+		})
+	} else {
+		res, err = bs.claimCol.Replace(c.ID, c, &gocb.ReplaceOptions{
+			Context: ctx,
+			Cas:     cas,
+		})
+	}
+	if errors.Is(err, gocb.ErrCasMismatch) {
+		return 0, ErrClaimChanged
+	}
+	if err != nil {
+		return 0, err
+	}
+	return res.Cas(), nil
+}
+
+// Claims returns the claims of the week containing week, oldest first.
+func (bs *BusinessStore) Claims(ctx context.Context, week time.Time) ([]ShiftClaim, error) {
+	res, err := bs.scope.Query(
+		"SELECT x.* FROM claims x WHERE x.week = $week ORDER BY x.createdAt",
+		&gocb.QueryOptions{
+			Context:         ctx,
+			ScanConsistency: gocb.QueryScanConsistencyRequestPlus,
+			NamedParameters: map[string]interface{}{
+				"week": store.StartOfWeek(week).Format(time.DateOnly),
+			},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close() // ignore error
+
+	claims := []ShiftClaim{}
+	for res.Next() {
+		var c ShiftClaim
+		err := res.Row(&c)
+		if err != nil {
+			return nil, err
+		}
+		claims = append(claims, c)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+	}
+
+	return claims, res.Err()
+}
+
+// ClaimShift lets c.Employee take an open place on a shift. Claims breaking
+// the rules return the violations; the others are applied right away unless
+// the business requires claims to be approved.
+func (bs *BusinessStore) ClaimShift(
+	ctx context.Context,
+	c ShiftClaim,
+	employees []store.Employee,
+	ava map[string]store.WeekAvailability,
+) (ShiftClaim, []Violation, error) {
+	date, err := c.date(bs.Location())
+	if err != nil {
+		return ShiftClaim{}, nil, err
+	}
+
+	claims, err := bs.Claims(ctx, date)
+	if err != nil {
+		return ShiftClaim{}, nil, err
+	}
+	for _, other := range claims {
+		if other.Status == ClaimPendingApproval && other.Employee == c.Employee &&
+			other.Date == c.Date && other.From == c.From && other.To == c.To {
+			return ShiftClaim{}, nil, ErrAlreadyClaimed
+		}
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return ShiftClaim{}, nil, err
+	}
+	c.ID = id.String()
+	c.Week = store.StartOfWeek(date).Format(time.DateOnly)
+	c.CreatedAt = time.Now().In(bs.Location())
+
+	violations, err := bs.checkClaim(ctx, c, employees, ava)
+	if err != nil || len(violations) > 0 {
+		return ShiftClaim{}, violations, err
+	}
+
+	settings, err := bs.GetSettings(ctx)
+	if err != nil {
+		return ShiftClaim{}, nil, err
+	}
+	if settings.RequireClaimApproval {
+		c.Status = ClaimPendingApproval
+		_, err = bs.putClaim(ctx, c, 0)
+		return c, nil, err
+	}
+
+	c, err = bs.applyClaim(ctx, c, 0, c.Employee)
+	return c, nil, err
+}
+
+// DecideClaim approves or rejects a pending claim. An approved claim is
+// checked again, as the place may have been filled since it was made.
+func (bs *BusinessStore) DecideClaim(
+	ctx context.Context,
+	id string,
+	approve bool,
+	actor string,
+	employees []store.Employee,
+	ava map[string]store.WeekAvailability,
+) (ShiftClaim, []Violation, error) {
+	c, cas, err := bs.getClaimForUpdate(ctx, id)
+	if err != nil {
+		return ShiftClaim{}, nil, err
+	}
+	if c.Status != ClaimPendingApproval {
+		return ShiftClaim{}, nil, ErrClaimClosed
+	}
+
+	now := time.Now().In(bs.Location())
+	c.DecidedBy = actor
+	c.DecidedAt = &now
+	if !approve {
+		c.Status = ClaimRejected
+		_, err = bs.putClaim(ctx, c, cas)
+		return c, nil, err
+	}
+
+	violations, err := bs.checkClaim(ctx, c, employees, ava)
+	if err != nil || len(violations) > 0 {
+		return ShiftClaim{}, violations, err
+	}
+
+	c, err = bs.applyClaim(ctx, c, cas, actor)
+	return c, nil, err
+}
+
+// CancelClaim withdraws a claim that is waiting for approval.
+func (bs *BusinessStore) CancelClaim(ctx context.Context, id string) (ShiftClaim, error) {
+	c, cas, err := bs.getClaimForUpdate(ctx, id)
+	if err != nil {
+		return ShiftClaim{}, err
+	}
+	if c.Status != ClaimPendingApproval {
+		return ShiftClaim{}, ErrClaimClosed
+	}
+
+	c.Status = ClaimCancelled
+	_, err = bs.putClaim(ctx, c, cas)
+	return c, err
+}
+
+// claimableShift returns the timetable shift claimed by c if it can still be
+// claimed: the schedule is published and the shift has not started.
+func (bs *BusinessStore) claimableShift(ctx context.Context, c ShiftClaim) (ShiftTimetable, error) {
+	date, err := c.date(bs.Location())
+	if err != nil {
+		return ShiftTimetable{}, err
+	}
+
+	status, err := bs.GetScheduleStatus(ctx, date)
+	if err != nil {
+		return ShiftTimetable{}, err
+	}
+	switch status.Status {
+	case StatusLocked:
+		return ShiftTimetable{}, ErrScheduleLocked
+	case StatusDraft:
+		return ShiftTimetable{}, ErrNotPublished
+	}
+
+	if start, _ := shiftInterval(date, c.From, c.To); !start.After(time.Now()) {
+		return ShiftTimetable{}, ErrShiftStarted
+	}
+
+	tt, err := bs.timetableForWeek(ctx, date)
+	if err != nil {
+		return ShiftTimetable{}, err
+	}
+	stt, ok := findShift(tt.Day(date.Weekday()), c.From, c.To)
+	if !ok {
+		return ShiftTimetable{}, ErrNoOpenPlace
+	}
+	return stt, nil
+}
+
+// checkClaim returns the violations the employee of c would cause by taking
+// the open place in the current schedule.
+func (bs *BusinessStore) checkClaim(
+	ctx context.Context,
+	c ShiftClaim,
+	employees []store.Employee,
+	ava map[string]store.WeekAvailability,
+) ([]Violation, error) {
+	stt, err := bs.claimableShift(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	date, err := c.date(bs.Location())
+	if err != nil {
+		return nil, err
+	}
+
+	ws, err := bs.GetScheduleForWeek(ctx, date)
+	if err != nil {
+		return nil, err
+	}
+	ws, err = addToShift(ws, date.Weekday(), stt, c.Employee, c.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := bs.ValidateScheduleForWeek(ctx, date, ws, employees, ava)
+	if err != nil {
+		return nil, err
+	}
+	return violationsOf(res.Violations, c.Employee), nil
+}
+
+// applyClaim adds the employee of c to the shift as a new version written by
+// actor. A stored claim is marked applied first and reopened on failure.
+func (bs *BusinessStore) applyClaim(ctx context.Context, c ShiftClaim, cas gocb.Cas, actor string) (ShiftClaim, error) {
+	stt, err := bs.claimableShift(ctx, c)
+	if err != nil {
+		return ShiftClaim{}, err
+	}
+	date, err := c.date(bs.Location())
+	if err != nil {
+		return ShiftClaim{}, err
+	}
+
+	prevStatus := c.Status
+	c.Status = ClaimApplied
+	if cas != 0 {
+		cas, err = bs.putClaim(ctx, c, cas)
+		if err != nil {
+			return ShiftClaim{}, err
+		}
+	}
+
+	sv, err := bs.referencedVersion(ctx, date, VersionClaimed, c.ID)
+	if errors.Is(err, ErrVersionNotFound) {
+		sv, err = bs.updateSchedule(ctx, date, ScheduleVersion{
+			Action:    VersionClaimed,
+			Actor:     actor,
+			Reference: c.ID,
+		}, func(prev WeekSchedule) (WeekSchedule, error) {
+			return addToShift(prev, date.Weekday(), stt, c.Employee, c.Role)
+		})
+	}
+	if err != nil {
+		if cas != 0 {
+			c.Status = prevStatus
+			if _, rerr := bs.putClaim(ctx, c, cas); rerr != nil {
+				bs.logger.Warn("failed to reopen claim", "id", c.ID, "err", rerr)
+			}
+		}
+		return ShiftClaim{}, err
+	}
+
+	c.Version = sv.Version
+	_, err = bs.putClaim(ctx, c, cas)
+	return c, err
+}
+
+// addToShift returns a copy of ws in which email works the shift stt,
+// working role if it is not empty. The shift must have an open place for the
+// role, or one not tied to a role if role is empty.
+func addToShift(ws WeekSchedule, weekday time.Weekday, stt ShiftTimetable, email string, role string) (WeekSchedule, error) {
+	ds := ws.Day(weekday)
+	shifts := make([]ShiftSchedule, len(ds.Shifts))
+	copy(shifts, ds.Shifts)
+
+	idx := slices.IndexFunc(shifts, func(s ShiftSchedule) bool {
+		return s.From == stt.From && s.To == stt.To
+	})
+	if idx < 0 {
+		shifts = append(shifts, ShiftSchedule{
+			From:      stt.From,
+			To:        stt.To,
+			Employees: []string{},
+		})
+		idx = len(shifts) - 1
+	}
+
+	s := shifts[idx]
+	if slices.Contains(s.Employees, email) {
+		return WeekSchedule{}, ErrAlreadyAssigned
+	}
+	if openPlaces(CoverShift(stt, s), role) == 0 {
+		return WeekSchedule{}, ErrNoOpenPlace
+	}
+
+	s.Employees = append(slices.Clone(s.Employees), email)
+	if role != "" {
+		roles := make(map[string][]string, len(s.Roles)+1)
+		for r, emails := range s.Roles {
+			roles[r] = emails
+		}
+		roles[role] = append(slices.Clone(roles[role]), email)
+		s.Roles = roles
+	}
+	shifts[idx] = s
+
+	ds.Shifts = shifts
+	ws.SetDay(weekday, ds)
+	return ws, nil
+}
+
+// openPlaces returns how many more employees may work role on a shift with
+// the given coverage. Places that are missing a role cannot be taken without
+// that role.
+func openPlaces(cov ShiftCoverage, role string) int {
+	if role != "" {
+		return min(cov.Missing, cov.MissingRoles[role])
+	}
+
+	free := cov.Missing
+	for _, missing := range cov.MissingRoles {
+		free -= missing
+	}
+	return max(free, 0)
+}
+
+// violationsOf returns the violations concerning the employee email.
+func violationsOf(violations []Violation, email string) []Violation {
+	own := []Violation{}
+	for _, v := range violations {
+		if v.Employee == email {
+			own = append(own, v)
+		}
+	}
+	return own
+}
@@ -0,0 +1,125 @@
+package business
+
+import (
+	"airdock/store"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+const (
+	OverrideDate = "date"
+	OverrideWeek = "week"
+)
+
+// TimetableOverride replaces the default timetable for a single date or for a
+// whole week. Date overrides take precedence over week overrides.
+type TimetableOverride struct {
+	Kind string `json:"kind"`
+	// Date is the date of a date override and the date of the Monday of a
+	// week override.
+	Date string `json:"date"`
+	Note string `json:"note,omitempty"`
+	// Day is set for date overrides. A day without shifts means closed.
+	Day *DayTimetable `json:"day,omitempty"`
+	// Week is set for week overrides.
+	Week *WeekTimetable `json:"week,omitempty"`
+}
+
+func overrideKey(kind string, date time.Time) string {
+	if kind == OverrideWeek {
+		date = store.StartOfWeek(date)
+	}
+	return kind + "-" + date.Format(time.DateOnly)
+}
+
+func (bs *BusinessStore) SetTimetableOverride(ctx context.Context, date time.Time, o TimetableOverride) (TimetableOverride, error) {
+	switch o.Kind {
+	case OverrideDate:
+		if o.Day == nil {
+			return TimetableOverride{}, errors.New("date override without a day timetable")
+		}
+		o.Week = nil
+	case OverrideWeek:
+		if o.Week == nil {
+			return TimetableOverride{}, errors.New("week override without a week timetable")
+		}
+		o.Day = nil
+		date = store.StartOfWeek(date)
+	default:
+		return TimetableOverride{}, fmt.Errorf("unknown override kind %q", o.Kind)
+	}
+	o.Date = date.Format(time.DateOnly)
+
+	_, err := bs.overrideCol.Upsert(overrideKey(o.Kind, date), o, &gocb.UpsertOptions{
+		Context: ctx,
+	})
+	return o, err
+}
+
+func (bs *BusinessStore) GetTimetableOverride(ctx context.Context, kind string, date time.Time) (TimetableOverride, error) {
+	res, err := bs.overrideCol.Get(overrideKey(kind, date), &gocb.GetOptions{
+		Context: ctx,
+	})
+	if errors.Is(err, gocb.ErrDocumentNotFound) {
+		return TimetableOverride{}, ErrConfigNotFound
+	}
+	if err != nil {
+		return TimetableOverride{}, err
+	}
+
+	var o TimetableOverride
+	err = res.Content(&o)
+	return o, err
+}
+
+func (bs *BusinessStore) DeleteTimetableOverride(ctx context.Context, kind string, date time.Time) error {
+	_, err := bs.overrideCol.Remove(overrideKey(kind, date), &gocb.RemoveOptions{
+		Context: ctx,
+	})
+	if errors.Is(err, gocb.ErrDocumentNotFound) {
+		return ErrConfigNotFound
+	}
+	return err
+}
+
+// TimetableOverrides returns all overrides whose date lies between from and to,
+// both inclusive, ordered by date.
+func (bs *BusinessStore) TimetableOverrides(ctx context.Context, from time.Time, to time.Time) ([]TimetableOverride, error) {
+	res, err := bs.scope.Query(
+		"SELECT x.* FROM overrides x WHERE x.date BETWEEN $from AND $to ORDER BY x.date, x.kind",
+		&gocb.QueryOptions{
+			Context: ctx,
+			NamedParameters: map[string]interface{}{
+				"from": from.Format(time.DateOnly),
+				"to":   to.Format(time.DateOnly),
+			},
+			ScanConsistency: gocb.QueryScanConsistencyRequestPlus,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close() // ignore error
+
+	overrides := []TimetableOverride{}
+	for res.Next() {
+		var o TimetableOverride
+		err := res.Row(&o)
+		if err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, o)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+	}
+
+	return overrides, res.Err()
+}
@@ -0,0 +1,451 @@
+package business
+
+import (
+	"airdock/store"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+const (
+	PayrollSettingsKey = "payroll-settings"
+)
+
+// Fields of a payroll export. Hours are decimal hours and pay is in major
+// units of the business currency.
+const (
+	PayrollEmployee      = "employee"
+	PayrollName          = "name"
+	PayrollFrom          = "from"
+	PayrollTo            = "to"
+	PayrollHourlyWage    = "hourly_wage"
+	PayrollNormalHours   = "normal_hours"
+	PayrollOvertimeHours = "overtime_hours"
+	PayrollNormalPay     = "normal_pay"
+	PayrollOvertimePay   = "overtime_pay"
+	PayrollSupplementPay = "supplement_pay"
+	PayrollTotal         = "total"
+	// PayrollRuleHoursPrefix and PayrollRulePayPrefix are followed by the
+	// name of a supplement rule, e.g. "rule_hours:night".
+	PayrollRuleHoursPrefix = "rule_hours:"
+	PayrollRulePayPrefix   = "rule_pay:"
+)
+
+var payrollFields = []string{
+	PayrollEmployee,
+	PayrollName,
+	PayrollFrom,
+	PayrollTo,
+	PayrollHourlyWage,
+	PayrollNormalHours,
+	PayrollOvertimeHours,
+	PayrollNormalPay,
+	PayrollOvertimePay,
+	PayrollSupplementPay,
+	PayrollTotal,
+}
+
+const (
+	AlignLeft  = "left"
+	AlignRight = "right"
+)
+
+// PayrollField is a column of a payroll export. Width and Align are only used
+// by fixed-width exports.
+type PayrollField struct {
+	Field string `json:"field"`
+	Label string `json:"label"`
+	Width int    `json:"width"`
+	Align string `json:"align"`
+}
+
+// PayrollSettings decides what counts as overtime and how payroll exports
+// are laid out. A zero overtime limit disables it.
+type PayrollSettings struct {
+	DailyOvertimeHours  float64        `json:"dailyOvertimeHours"`
+	WeeklyOvertimeHours float64        `json:"weeklyOvertimeHours"`
+	OvertimePercent     float64        `json:"overtimePercent"`
+	Layout              []PayrollField `json:"layout"`
+}
+
+func defaultPayrollSettings() PayrollSettings {
+	return PayrollSettings{
+		WeeklyOvertimeHours: 40,
+		OvertimePercent:     50,
+		Layout:              []PayrollField{},
+	}
+}
+
+func (bs *BusinessStore) GetPayrollSettings(ctx context.Context) (PayrollSettings, error) {
+	res, err := bs.configCol.Get(PayrollSettingsKey, &gocb.GetOptions{
+		Context: ctx,
+	})
+	if errors.Is(err, gocb.ErrDocumentNotFound) {
+		return defaultPayrollSettings(), nil
+	}
+	if err != nil {
+		return PayrollSettings{}, err
+	}
+
+	var ps PayrollSettings
+	err = res.Content(&ps)
+	return ps, err
+}
+
+func (bs *BusinessStore) SetPayrollSettings(ctx context.Context, ps PayrollSettings) error {
+	if ps.DailyOvertimeHours < 0 || ps.WeeklyOvertimeHours < 0 || ps.OvertimePercent < 0 {
+		return errors.New("overtime limits and percent must not be negative")
+	}
+	for _, f := range ps.Layout {
+		if !IsPayrollField(f.Field) {
+			return fmt.Errorf("unknown payroll field %q", f.Field)
+		}
+		if f.Width < 1 {
+			return fmt.Errorf("payroll field %q needs a width", f.Field)
+		}
+		if f.Align != AlignLeft && f.Align != AlignRight {
+			return fmt.Errorf("unknown alignment %q", f.Align)
+		}
+	}
+	return bs.SetConfig(ctx, PayrollSettingsKey, ps)
+}
+
+// IsPayrollField reports whether field can be part of a payroll layout.
+func IsPayrollField(field string) bool {
+	for _, f := range payrollFields {
+		if f == field {
+			return true
+		}
+	}
+	for _, prefix := range []string{PayrollRuleHoursPrefix, PayrollRulePayPrefix} {
+		if rule, ok := strings.CutPrefix(field, prefix); ok && rule != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultPayrollLayout exports every field, with hours and pay columns for
+// each of the given supplement rules.
+func DefaultPayrollLayout(rules []string) []PayrollField {
+	text := func(field string, width int) PayrollField {
+		return PayrollField{Field: field, Label: field, Width: width, Align: AlignLeft}
+	}
+	number := func(field string, width int) PayrollField {
+		return PayrollField{Field: field, Label: field, Width: width, Align: AlignRight}
+	}
+
+	layout := []PayrollField{
+		text(PayrollEmployee, 40),
+		text(PayrollName, 30),
+		text(PayrollFrom, 10),
+		text(PayrollTo, 10),
+		number(PayrollHourlyWage, 10),
+		number(PayrollNormalHours, 8),
+		number(PayrollOvertimeHours, 8),
+	}
+	for _, rule := range rules {
+		layout = append(layout, number(PayrollRuleHoursPrefix+rule, 8))
+	}
+	layout = append(layout,
+		number(PayrollNormalPay, 12),
+		number(PayrollOvertimePay, 12),
+	)
+	for _, rule := range rules {
+		layout = append(layout, number(PayrollRulePayPrefix+rule, 12))
+	}
+	return append(layout,
+		number(PayrollSupplementPay, 12),
+		number(PayrollTotal, 12),
+	)
+}
+
+// ruleNames returns the names of the supplement rules of all agreements.
+func (ss SupplementSettings) ruleNames() []string {
+	seen := make(map[string]bool)
+	for _, rules := range ss.Agreements {
+		for _, r := range rules {
+			seen[r.Name] = true
+		}
+	}
+	return sortedKeys(seen)
+}
+
+// PayrollLine is the approved work of an employee in the period of a
+// payroll. Pay is in minor units of the business currency.
+type PayrollLine struct {
+	Employee        string          `json:"employee"`
+	Name            string          `json:"name"`
+	HourlyWage      int64           `json:"hourlyWage"`
+	NormalMinutes   int             `json:"normalMinutes"`
+	OvertimeMinutes int             `json:"overtimeMinutes"`
+	Supplements     []SupplementPay `json:"supplements"`
+	NormalPay       int64           `json:"normalPay"`
+	OvertimePay     int64           `json:"overtimePay"`
+	SupplementPay   int64           `json:"supplementPay"`
+	Total           int64           `json:"total"`
+}
+
+// Payroll is what the employees earned from From up to and including To.
+// Only work in approved timesheets is paid; Unapproved lists the employees
+// with other finished work in the period.
+type Payroll struct {
+	From       string         `json:"from"`
+	To         string         `json:"to"`
+	Currency   string         `json:"currency"`
+	Layout     []PayrollField `json:"layout"`
+	Lines      []PayrollLine  `json:"lines"`
+	Unapproved []string       `json:"unapproved"`
+}
+
+// Payroll works out the pay of every employee with approved time entries,
+// taking names and wages from employees.
+func (bs *BusinessStore) Payroll(ctx context.Context, from time.Time, to time.Time, employees []store.Employee) (Payroll, error) {
+	settings, err := bs.GetSettings(ctx)
+	if err != nil {
+		return Payroll{}, err
+	}
+	ps, err := bs.GetPayrollSettings(ctx)
+	if err != nil {
+		return Payroll{}, err
+	}
+
+	weekFrom := store.StartOfWeek(from)
+	weekTo := store.StartOfWeek(to).AddDate(0, 0, 6)
+	entries, err := bs.TimeEntries(ctx, "", weekFrom.Format(time.DateOnly), weekTo.Format(time.DateOnly))
+	if err != nil {
+		return Payroll{}, err
+	}
+	approvals, err := bs.approvedTimesheets(ctx, weekFrom.Format(time.DateOnly), weekTo.Format(time.DateOnly))
+	if err != nil {
+		return Payroll{}, err
+	}
+	pc, err := bs.PayCalculator(ctx, weekFrom, weekTo)
+	if err != nil {
+		return Payroll{}, err
+	}
+
+	p := Payroll{
+		From:       from.Format(time.DateOnly),
+		To:         to.Format(time.DateOnly),
+		Currency:   settings.Currency,
+		Layout:     ps.Layout,
+		Lines:      []PayrollLine{},
+		Unapproved: []string{},
+	}
+	if len(p.Layout) == 0 {
+		p.Layout = DefaultPayrollLayout(pc.settings.ruleNames())
+	}
+
+	byEmployee := make(map[string][]TimeEntry)
+	for _, te := range entries {
+		if te.Open() {
+			continue
+		}
+		if !approved(approvals, te) {
+			continue
+		}
+		byEmployee[te.Employee] = append(byEmployee[te.Employee], te)
+	}
+
+	known := make(map[string]store.Employee, len(employees))
+	for _, e := range employees {
+		known[e.Email] = e
+	}
+	for _, email := range sortedKeys(byEmployee) {
+		// employees deleted since are still paid for their approved work
+		e, ok := known[email]
+		if !ok {
+			e = store.Employee{Email: email}
+		}
+		line := payrollLine(e, byEmployee[email], weekFrom, from, to, ps, pc)
+		if line.NormalMinutes+line.OvertimeMinutes > 0 {
+			p.Lines = append(p.Lines, line)
+		}
+	}
+	for _, te := range entries {
+		if !te.Open() && te.Date >= p.From && te.Date <= p.To && !approved(approvals, te) {
+			p.Unapproved = append(p.Unapproved, te.Employee)
+		}
+	}
+	sort.Strings(p.Unapproved)
+	p.Unapproved = slices.Compact(p.Unapproved)
+	return p, nil
+}
+
+func approved(approvals []TimesheetApproval, te TimeEntry) bool {
+	for _, ta := range approvals {
+		if ta.Employee == te.Employee && ta.From <= te.Date && te.Date <= ta.To {
+			return true
+		}
+	}
+	return false
+}
+
+// payrollLine adds up the work of e in entries, which cover whole weeks from
+// weekFrom, and pays the part from from to to.
+func payrollLine(e store.Employee, entries []TimeEntry, weekFrom time.Time, from time.Time, to time.Time, ps PayrollSettings, pc PayCalculator) PayrollLine {
+	line := PayrollLine{
+		Employee:    e.Email,
+		Name:        e.Name,
+		HourlyWage:  e.HourlyWage,
+		Supplements: []SupplementPay{},
+	}
+	fromStr, toStr := from.Format(time.DateOnly), to.Format(time.DateOnly)
+
+	worked := make(map[string]int)
+	byRule := make(map[string]int)
+	for _, te := range entries {
+		worked[te.Date] += int(te.Worked().Minutes())
+		if te.Date < fromStr || te.Date > toStr {
+			continue
+		}
+		for _, seg := range te.segments() {
+			for _, sp := range pc.Pay(e, seg.start, seg.end).Supplements {
+				i, ok := byRule[sp.Rule]
+				if !ok {
+					i = len(line.Supplements)
+					byRule[sp.Rule] = i
+					line.Supplements = append(line.Supplements, SupplementPay{Rule: sp.Rule})
+				}
+				line.Supplements[i].Minutes += sp.Minutes
+				line.Supplements[i].Amount += sp.Amount
+			}
+		}
+	}
+
+	dailyLimit := int(ps.DailyOvertimeHours * 60)
+	weeklyLimit := int(ps.WeeklyOvertimeHours * 60)
+	for week := weekFrom; !week.After(to); week = week.AddDate(0, 0, 7) {
+		weekNormal := 0
+		for i := range Weekdays {
+			date := week.AddDate(0, 0, i).Format(time.DateOnly)
+			normal, overtime := worked[date], 0
+			if dailyLimit > 0 && normal > dailyLimit {
+				overtime = normal - dailyLimit
+				normal = dailyLimit
+			}
+			if weeklyLimit > 0 && weekNormal+normal > weeklyLimit {
+				excess := min(weekNormal+normal-weeklyLimit, normal)
+				overtime += excess
+				normal -= excess
+			}
+			weekNormal += normal
+
+			if date >= fromStr && date <= toStr {
+				line.NormalMinutes += normal
+				line.OvertimeMinutes += overtime
+			}
+		}
+	}
+
+	overtimeWage := e.HourlyWage + int64(math.Round(float64(e.HourlyWage)*ps.OvertimePercent/100))
+	line.NormalPay = wageCost(e.HourlyWage, line.NormalMinutes)
+	line.OvertimePay = wageCost(overtimeWage, line.OvertimeMinutes)
+	for _, sp := range line.Supplements {
+		line.SupplementPay += sp.Amount
+	}
+	line.Total = line.NormalPay + line.OvertimePay + line.SupplementPay
+	return line
+}
+
+// Value returns the value of field for line: a string for text fields and a
+// number of hours or major currency units otherwise.
+func (p Payroll) Value(line PayrollLine, field string) interface{} {
+	hours := func(minutes int) float64 {
+		return math.Round(float64(minutes)/60*100) / 100
+	}
+	money := func(minor int64) float64 {
+		return float64(minor) / 100
+	}
+
+	switch field {
+	case PayrollEmployee:
+		return line.Employee
+	case PayrollName:
+		return line.Name
+	case PayrollFrom:
+		return p.From
+	case PayrollTo:
+		return p.To
+	case PayrollHourlyWage:
+		return money(line.HourlyWage)
+	case PayrollNormalHours:
+		return hours(line.NormalMinutes)
+	case PayrollOvertimeHours:
+		return hours(line.OvertimeMinutes)
+	case PayrollNormalPay:
+		return money(line.NormalPay)
+	case PayrollOvertimePay:
+		return money(line.OvertimePay)
+	case PayrollSupplementPay:
+		return money(line.SupplementPay)
+	case PayrollTotal:
+		return money(line.Total)
+	}
+
+	if rule, ok := strings.CutPrefix(field, PayrollRuleHoursPrefix); ok {
+		for _, sp := range line.Supplements {
+			if sp.Rule == rule {
+				return hours(sp.Minutes)
+			}
+		}
+		return 0.0
+	}
+	if rule, ok := strings.CutPrefix(field, PayrollRulePayPrefix); ok {
+		for _, sp := range line.Supplements {
+			if sp.Rule == rule {
+				return money(sp.Amount)
+			}
+		}
+		return 0.0
+	}
+	return ""
+}
+
+// approvedTimesheets returns the approved timesheets overlapping the days
+// from from to to, formatted as 2006-01-02.
+func (bs *BusinessStore) approvedTimesheets(ctx context.Context, from string, to string) ([]TimesheetApproval, error) {
+	res, err := bs.scope.Query(
+		"SELECT x.* FROM timesheets x WHERE x.status = $status AND x.`from` <= $to AND x.`to` >= $from",
+		&gocb.QueryOptions{
+			Context: ctx,
+			NamedParameters: map[string]interface{}{
+				"status": TimesheetApproved,
+				"from":   from,
+				"to":     to,
+			},
+			// work approved just before the export must be paid
+			ScanConsistency: gocb.QueryScanConsistencyRequestPlus,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close() // ignore error
+
+	approvals := []TimesheetApproval{}
+	for res.Next() {
+		var ta TimesheetApproval
+		err := res.Row(&ta)
+		if err != nil {
+			return nil, err
+		}
+		approvals = append(approvals, ta)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+	}
+
+	return approvals, res.Err()
+}
@@ -0,0 +1,143 @@
+package business
+
+import (
+	"airdock/store"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPayrollLine(t *testing.T) {
+	finished := func(dayIdx int, from string, to string) TimeEntry {
+		ws := work(dayIdx, from, to)
+		return TimeEntry{
+			Employee: "a@x.se",
+			Date:     ws.start.Format(time.DateOnly),
+			ClockIn:  ws.start,
+			ClockOut: &ws.end,
+		}
+	}
+	days := func(n int, from string, to string) []TimeEntry {
+		var entries []TimeEntry
+		for i := range n {
+			entries = append(entries, finished(i, from, to))
+		}
+		return entries
+	}
+	evening := NewPayCalculator(SupplementSettings{
+		Default: "default",
+		Agreements: map[string][]SupplementRule{
+			"default": {{
+				Name:     "evening",
+				From:     clock("18:00"),
+				To:       clock("22:00"),
+				Weekdays: []string{"monday", "tuesday", "wednesday", "thursday", "friday"},
+				Percent:  20,
+			}},
+		},
+	}, nil)
+	none := NewPayCalculator(defaultSupplementSettings(), nil)
+	employee := store.Employee{Email: "a@x.se", Name: "A", HourlyWage: 20000}
+
+	tests := []struct {
+		name        string
+		entries     []TimeEntry
+		from        time.Time
+		to          time.Time
+		ps          PayrollSettings
+		pc          PayCalculator
+		want        PayrollLine
+		supplements []SupplementPay
+	}{
+		{
+			name:    "weekly overtime counts the days before the period",
+			entries: days(5, "10:00", "19:00"),
+			from:    testWeek.AddDate(0, 0, 2),
+			to:      testWeek.AddDate(0, 0, 6),
+			ps:      defaultPayrollSettings(),
+			pc:      evening,
+			want: PayrollLine{
+				NormalMinutes:   1320,
+				OvertimeMinutes: 300,
+				NormalPay:       440000,
+				OvertimePay:     150000,
+				SupplementPay:   12000,
+				Total:           602000,
+			},
+			supplements: []SupplementPay{{Rule: "evening", Minutes: 180, Amount: 12000}},
+		},
+		{
+			name:    "daily overtime",
+			entries: days(1, "10:00", "19:00"),
+			from:    testWeek,
+			to:      testWeek.AddDate(0, 0, 6),
+			ps:      PayrollSettings{DailyOvertimeHours: 8, OvertimePercent: 50},
+			pc:      none,
+			want: PayrollLine{
+				NormalMinutes:   480,
+				OvertimeMinutes: 60,
+				NormalPay:       160000,
+				OvertimePay:     30000,
+				Total:           190000,
+			},
+		},
+		{
+			name:    "daily and weekly overtime together",
+			entries: append(days(5, "09:00", "17:00"), finished(5, "08:00", "18:00")),
+			from:    testWeek,
+			to:      testWeek.AddDate(0, 0, 6),
+			ps:      PayrollSettings{DailyOvertimeHours: 8, WeeklyOvertimeHours: 40, OvertimePercent: 50},
+			pc:      none,
+			want: PayrollLine{
+				NormalMinutes:   2400,
+				OvertimeMinutes: 600,
+				NormalPay:       800000,
+				OvertimePay:     300000,
+				Total:           1100000,
+			},
+		},
+		{
+			name:    "overtime limits disabled",
+			entries: days(7, "08:00", "20:00"),
+			from:    testWeek,
+			to:      testWeek.AddDate(0, 0, 6),
+			ps:      PayrollSettings{OvertimePercent: 50},
+			pc:      none,
+			want: PayrollLine{
+				NormalMinutes: 5040,
+				NormalPay:     1680000,
+				Total:         1680000,
+			},
+		},
+		{
+			name:    "weekly overtime starts again every week",
+			entries: append(days(5, "08:00", "17:00"), finished(7, "08:00", "17:00")),
+			from:    testWeek,
+			to:      testWeek.AddDate(0, 0, 13),
+			ps:      defaultPayrollSettings(),
+			pc:      none,
+			want: PayrollLine{
+				NormalMinutes:   2940,
+				OvertimeMinutes: 300,
+				NormalPay:       980000,
+				OvertimePay:     150000,
+				Total:           1130000,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := payrollLine(employee, tc.entries, testWeek, tc.from, tc.to, tc.ps, tc.pc)
+			if !slices.Equal(got.Supplements, tc.supplements) {
+				t.Errorf("supplements: got %v, want %v", got.Supplements, tc.supplements)
+			}
+			tc.want.Employee, tc.want.Name, tc.want.HourlyWage = employee.Email, employee.Name, employee.HourlyWage
+			tc.want.Supplements = got.Supplements
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
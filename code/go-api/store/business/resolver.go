@@ -0,0 +1,169 @@
+package business
+
+import (
+	"airdock/store"
+	"context"
+	"errors"
+	"time"
+)
+
+// timetableResolver resolves the effective timetable of any day from the
+// default timetable, the assigned templates, the overrides and the holidays
+// loaded for a range of dates.
+type timetableResolver struct {
+	defaultTt   WeekTimetable
+	assignments []TemplateAssignment
+	templates   map[string]TimetableTemplate
+	weeks       map[string]WeekTimetable
+	dates       map[string]DayTimetable
+	holidays    map[string]Holiday
+	hs          HolidaySettings
+}
+
+func newTimetableResolver(
+	defaultTt WeekTimetable,
+	overrides []TimetableOverride,
+	holidays []Holiday,
+	hs HolidaySettings,
+) timetableResolver {
+	r := timetableResolver{
+		defaultTt: defaultTt,
+		weeks:     make(map[string]WeekTimetable),
+		dates:     make(map[string]DayTimetable),
+		holidays:  make(map[string]Holiday, len(holidays)),
+		hs:        hs,
+	}
+	for _, h := range holidays {
+		r.holidays[h.Date] = h
+	}
+	for _, o := range overrides {
+		switch {
+		case o.Kind == OverrideWeek && o.Week != nil:
+			r.weeks[o.Date] = *o.Week
+		case o.Kind == OverrideDate && o.Day != nil:
+			r.dates[o.Date] = *o.Day
+		}
+	}
+	return r
+}
+
+// timetableResolver loads what is needed to resolve the timetable of every
+// day from from up to and including to, as well as of the week before, whose
+// overnight shifts may continue into the range.
+func (bs *BusinessStore) timetableResolver(ctx context.Context, from time.Time, to time.Time) (timetableResolver, error) {
+	assignments, err := bs.GetTemplateAssignments(ctx)
+	if err != nil {
+		return timetableResolver{}, err
+	}
+	templates, err := bs.templatesByName(ctx, assignments)
+	if err != nil {
+		return timetableResolver{}, err
+	}
+
+	// without a default timetable only the weeks covered by templates have
+	// shifts
+	defaultTt, err := bs.GetDefaultTimetable(ctx)
+	if err != nil && (!errors.Is(err, ErrConfigNotFound) || len(templates) == 0) {
+		return timetableResolver{}, err
+	}
+
+	from = store.StartOfWeek(from).AddDate(0, 0, -7)
+	overrides, err := bs.TimetableOverrides(ctx, from, to)
+	if err != nil {
+		return timetableResolver{}, err
+	}
+
+	hs, err := bs.GetHolidaySettings(ctx)
+	if err != nil {
+		return timetableResolver{}, err
+	}
+	holidays := []Holiday{}
+	if hs.Country != "" {
+		holidays, err = Holidays(hs.Country, from, to)
+		if err != nil {
+			return timetableResolver{}, err
+		}
+	}
+
+	r := newTimetableResolver(defaultTt, overrides, holidays, hs)
+	r.assignments = assignments
+	r.templates = templates
+	return r, nil
+}
+
+// day resolves the timetable of date. A date override wins over the holiday
+// rules, which win over week overrides and the default timetable.
+func (r timetableResolver) day(date time.Time) DayTimetable {
+	dateStr := date.Format(time.DateOnly)
+	h, isHoliday := r.holidays[dateStr]
+
+	dtt, ok := r.dates[dateStr]
+	if !ok {
+		weekday := date.Weekday()
+		if isHoliday {
+			switch r.hs.rule(h) {
+			case HolidayClosed:
+				weekday = -1
+			case HolidaySunday:
+				weekday = time.Sunday
+			}
+		}
+		dtt = r.weekDay(date, weekday)
+	}
+
+	if isHoliday {
+		dtt.Holiday = &h
+	}
+	return dtt
+}
+
+// weekDay returns the timetable of weekday in the week containing date,
+// ignoring date overrides and holidays. A negative weekday has no shifts.
+func (r timetableResolver) weekDay(date time.Time, weekday time.Weekday) DayTimetable {
+	if weekday < 0 {
+		return DayTimetable{
+			Shifts: []ShiftTimetable{},
+		}
+	}
+	return r.base(store.StartOfWeek(date)).Day(weekday)
+}
+
+// base returns the timetable of the week starting at weekStart before date
+// overrides and holidays are applied: a week override, else the template
+// assigned last, else the default timetable.
+func (r timetableResolver) base(weekStart time.Time) WeekTimetable {
+	if wtt, ok := r.weeks[weekStart.Format(time.DateOnly)]; ok {
+		return wtt
+	}
+	if t, ok := r.template(weekStart); ok {
+		// templates are checked when the resolver is loaded
+		wtt, _ := t.Week(weekStart)
+		return wtt
+	}
+	return r.defaultTt
+}
+
+// template returns the template assigned last to the week starting at
+// weekStart, if any.
+func (r timetableResolver) template(weekStart time.Time) (TimetableTemplate, bool) {
+	for i := len(r.assignments) - 1; i >= 0; i-- {
+		ta := r.assignments[i]
+		if !ta.covers(weekStart) {
+			continue
+		}
+		if t, ok := r.templates[ta.Template]; ok {
+			return t, true
+		}
+	}
+	return TimetableTemplate{}, false
+}
+
+func (r timetableResolver) week(week time.Time) WeekTimetable {
+	weekStart := store.StartOfWeek(week)
+
+	var tt WeekTimetable
+	for dayIdx, weekday := range Weekdays {
+		tt.setDay(weekday, r.day(weekStart.AddDate(0, 0, dayIdx)))
+	}
+	return tt
+}
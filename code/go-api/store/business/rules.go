@@ -0,0 +1,387 @@
+package business
+
+import (
+	"airdock/store"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+const (
+	RuleSettingsKey = "rule-settings"
+)
+
+const (
+	ViolationDailyRest       = "insufficient_daily_rest"
+	ViolationWeeklyRest      = "insufficient_weekly_rest"
+	ViolationWeeklyHours     = "too_many_weekly_hours"
+	ViolationConsecutiveDays = "too_many_consecutive_days"
+)
+
+// RuleProfile holds the working-time limits a schedule must respect. A zero
+// value disables the corresponding rule.
+type RuleProfile struct {
+	MinDailyRestHours  float64 `json:"minDailyRestHours"`
+	MinWeeklyRestHours float64 `json:"minWeeklyRestHours"`
+	MaxWeeklyHours     float64 `json:"maxWeeklyHours"`
+	MaxConsecutiveDays int     `json:"maxConsecutiveDays"`
+}
+
+// DefaultRuleProfile follows the Swedish Working Hours Act.
+var DefaultRuleProfile = RuleProfile{
+	MinDailyRestHours:  11,
+	MinWeeklyRestHours: 36,
+	MaxWeeklyHours:     48,
+	MaxConsecutiveDays: 6,
+}
+
+type RuleSettings struct {
+	Active   string                 `json:"active"`
+	Profiles map[string]RuleProfile `json:"profiles"`
+}
+
+func defaultRuleSettings() RuleSettings {
+	return RuleSettings{
+		Active: "default",
+		Profiles: map[string]RuleProfile{
+			"default": DefaultRuleProfile,
+		},
+	}
+}
+
+func (bs *BusinessStore) GetRuleSettings(ctx context.Context) (RuleSettings, error) {
+	res, err := bs.configCol.Get(RuleSettingsKey, &gocb.GetOptions{
+		Context: ctx,
+	})
+	if errors.Is(err, gocb.ErrDocumentNotFound) {
+		return defaultRuleSettings(), nil
+	}
+	if err != nil {
+		return RuleSettings{}, err
+	}
+
+	var rs RuleSettings
+	err = res.Content(&rs)
+	return rs, err
+}
+
+func (bs *BusinessStore) SetRuleSettings(ctx context.Context, rs RuleSettings) error {
+	if _, ok := rs.Profiles[rs.Active]; !ok {
+		return fmt.Errorf("active rule profile %q does not exist", rs.Active)
+	}
+	return bs.SetConfig(ctx, RuleSettingsKey, rs)
+}
+
+func (bs *BusinessStore) activeRuleProfile(ctx context.Context) (RuleProfile, error) {
+	rs, err := bs.GetRuleSettings(ctx)
+	if err != nil {
+		return RuleProfile{}, err
+	}
+	p, ok := rs.Profiles[rs.Active]
+	if !ok {
+		return RuleProfile{}, fmt.Errorf("active rule profile %q does not exist", rs.Active)
+	}
+	return p, nil
+}
+
+// ComplianceContext is what is needed besides the schedule itself to check
+// it against a rule profile: the rules and the schedules of the surrounding
+// weeks, since rest periods and runs of working days cross week boundaries.
+type ComplianceContext struct {
+	Profile  RuleProfile
+	Previous WeekSchedule
+	Next     WeekSchedule
+}
+
+func (bs *BusinessStore) complianceContext(ctx context.Context, week time.Time) (ComplianceContext, error) {
+	profile, err := bs.activeRuleProfile(ctx)
+	if err != nil {
+		return ComplianceContext{}, err
+	}
+
+	prev, err := bs.GetScheduleForWeek(ctx, week.AddDate(0, 0, -7))
+	if err != nil && !errors.Is(err, ErrConfigNotFound) {
+		return ComplianceContext{}, err
+	}
+	next, err := bs.GetScheduleForWeek(ctx, week.AddDate(0, 0, 7))
+	if err != nil && !errors.Is(err, ErrConfigNotFound) {
+		return ComplianceContext{}, err
+	}
+
+	return ComplianceContext{
+		Profile:  profile,
+		Previous: prev,
+		Next:     next,
+	}, nil
+}
+
+type ComplianceReport struct {
+	Compliant bool                   `json:"compliant"`
+	Profile   RuleProfile            `json:"profile"`
+	Employees map[string][]Violation `json:"employees"`
+}
+
+func (bs *BusinessStore) CheckComplianceForWeek(ctx context.Context, week time.Time, ws WeekSchedule) (ComplianceReport, error) {
+	cc, err := bs.complianceContext(ctx, week)
+	if err != nil {
+		return ComplianceReport{}, err
+	}
+
+	return CheckCompliance(week, ws, cc), nil
+}
+
+// CheckCompliance evaluates every employee in ws against the rule profile.
+// Only violations that involve the week of ws are reported.
+func CheckCompliance(week time.Time, ws WeekSchedule, cc ComplianceContext) ComplianceReport {
+	weekStart := store.StartOfWeek(week)
+	shifts := workShiftsByEmployee(weekStart, ws, cc)
+
+	report := ComplianceReport{
+		Compliant: true,
+		Profile:   cc.Profile,
+		Employees: make(map[string][]Violation),
+	}
+	for email, ss := range shifts {
+		violations := cc.Profile.evaluate(weekStart, email, ss)
+		if len(violations) > 0 {
+			report.Compliant = false
+			report.Employees[email] = violations
+		}
+	}
+
+	return report
+}
+
+// Violations returns the violations of all employees ordered by email.
+func (cr ComplianceReport) Violations() []Violation {
+	var violations []Violation
+	for _, email := range sortedKeys(cr.Employees) {
+		violations = append(violations, cr.Employees[email]...)
+	}
+	return violations
+}
+
+type workShift struct {
+	start time.Time
+	end   time.Time
+}
+
+func workShiftsByEmployee(weekStart time.Time, ws WeekSchedule, cc ComplianceContext) map[string][]workShift {
+	shifts := make(map[string][]workShift)
+	add := func(weekStart time.Time, ws WeekSchedule) {
+		for dayIdx, weekday := range Weekdays {
+			date := weekStart.AddDate(0, 0, dayIdx)
+			for _, s := range ws.Day(weekday).Shifts {
+				start, end := shiftInterval(date, s.From, s.To)
+				for _, email := range s.Employees {
+					shifts[email] = append(shifts[email], workShift{start: start, end: end})
+				}
+			}
+		}
+	}
+	add(weekStart.AddDate(0, 0, -7), cc.Previous)
+	add(weekStart, ws)
+	add(weekStart.AddDate(0, 0, 7), cc.Next)
+
+	for _, ss := range shifts {
+		sortWorkShifts(ss)
+	}
+	return shifts
+}
+
+func sortWorkShifts(ss []workShift) {
+	sort.Slice(ss, func(i, j int) bool {
+		return ss[i].start.Before(ss[j].start)
+	})
+}
+
+// shiftInterval returns the instants a shift starts and ends when started on
+// the given date, in the location of date. Overnight shifts end on the
+// following day.
+func shiftInterval(date time.Time, from store.Clock, to store.Clock) (time.Time, time.Time) {
+	start := from.On(date)
+	if isOvernight(from, to) {
+		date = date.AddDate(0, 0, 1)
+	}
+	return start, to.On(date)
+}
+
+func overlaps(a workShift, b workShift) bool {
+	return a.start.Before(b.end) && b.start.Before(a.end)
+}
+
+func dayOf(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+func hours(f float64) time.Duration {
+	return time.Duration(f * float64(time.Hour))
+}
+
+// evaluate checks the sorted shifts of one employee, spanning the week
+// starting at weekStart and its neighbours, against the profile.
+func (p RuleProfile) evaluate(weekStart time.Time, email string, shifts []workShift) []Violation {
+	weekEnd := weekStart.AddDate(0, 0, 7)
+	inWeek := func(t time.Time) bool {
+		return !t.Before(weekStart) && t.Before(weekEnd)
+	}
+	weekViolation := func(code string, severity float64, msg string) Violation {
+		return Violation{
+			Code:     code,
+			Date:     weekStart.Format(time.DateOnly),
+			Employee: email,
+			Message:  msg,
+			severity: severity,
+		}
+	}
+
+	var violations []Violation
+
+	if p.MinDailyRestHours > 0 {
+		minRest := hours(p.MinDailyRestHours)
+		for i := 1; i < len(shifts); i++ {
+			prev, cur := shifts[i-1], shifts[i]
+			// shifts starting the same day as the previous one are part of
+			// the same working day, e.g. split shifts
+			if dayOf(cur.start).Equal(dayOf(prev.start)) {
+				continue
+			}
+			if !inWeek(cur.start) && !inWeek(prev.end) {
+				continue
+			}
+			rest := cur.start.Sub(prev.end)
+			if rest < minRest {
+				from, to := cur.start, cur.end
+				violations = append(violations, Violation{
+					Code:     ViolationDailyRest,
+					Date:     cur.start.Format(time.DateOnly),
+					Weekday:  cur.start.Weekday().String(),
+					From:     &from,
+					To:       &to,
+					Employee: email,
+					Message:  fmt.Sprintf("only %s rest before shift, at least %s required", rest, minRest),
+					severity: (minRest - rest).Hours(),
+				})
+			}
+		}
+	}
+
+	if p.MinWeeklyRestHours > 0 {
+		// only rest within the week itself counts, shifts from the
+		// neighbouring weeks matter when they cross the boundary
+		restStart := weekStart
+		longest := time.Duration(0)
+		for _, s := range shifts {
+			if !s.end.After(weekStart) || !s.start.Before(weekEnd) {
+				continue
+			}
+			longest = max(longest, s.start.Sub(restStart))
+			if s.end.After(restStart) {
+				restStart = s.end
+			}
+		}
+		if restStart.Before(weekEnd) {
+			longest = max(longest, weekEnd.Sub(restStart))
+		}
+
+		minRest := hours(p.MinWeeklyRestHours)
+		if longest < minRest {
+			violations = append(violations, weekViolation(
+				ViolationWeeklyRest,
+				(minRest-longest).Hours(),
+				fmt.Sprintf("longest rest in the week is %s, at least %s required", longest, minRest),
+			))
+		}
+	}
+
+	if p.MaxWeeklyHours > 0 {
+		worked := time.Duration(0)
+		for _, s := range shifts {
+			if inWeek(s.start) {
+				worked += s.end.Sub(s.start)
+			}
+		}
+		if worked > hours(p.MaxWeeklyHours) {
+			violations = append(violations, weekViolation(
+				ViolationWeeklyHours,
+				worked.Hours()-p.MaxWeeklyHours,
+				fmt.Sprintf("%.1f hours scheduled, at most %.1f allowed", worked.Hours(), p.MaxWeeklyHours),
+			))
+		}
+	}
+
+	if p.MaxConsecutiveDays > 0 {
+		var days []time.Time
+		for _, s := range shifts {
+			day := dayOf(s.start)
+			if len(days) == 0 || !days[len(days)-1].Equal(day) {
+				days = append(days, day)
+			}
+		}
+
+		runStart := 0
+		for i := 1; i <= len(days); i++ {
+			if i < len(days) && days[i].Equal(days[i-1].AddDate(0, 0, 1)) {
+				continue
+			}
+			run := days[runStart:i]
+			touchesWeek := false
+			for _, d := range run {
+				touchesWeek = touchesWeek || inWeek(d)
+			}
+			if len(run) > p.MaxConsecutiveDays && touchesWeek {
+				violations = append(violations, weekViolation(
+					ViolationConsecutiveDays,
+					float64(len(run)-p.MaxConsecutiveDays),
+					fmt.Sprintf(
+						"works %d consecutive days from %s to %s, at most %d allowed",
+						len(run),
+						run[0].Format(time.DateOnly),
+						run[len(run)-1].Format(time.DateOnly),
+						p.MaxConsecutiveDays,
+					),
+				))
+			}
+			runStart = i
+		}
+	}
+
+	return violations
+}
+
+// allows reports whether adding s to the sorted shifts of an employee keeps
+// them within the profile. Violations that were already there are ignored as
+// long as s does not make them worse.
+func (p RuleProfile) allows(weekStart time.Time, shifts []workShift, s workShift) bool {
+	with := make([]workShift, 0, len(shifts)+1)
+	with = append(with, shifts...)
+	with = append(with, s)
+	sortWorkShifts(with)
+
+	before := violationSeverities(p.evaluate(weekStart, "", shifts))
+	for key, severity := range violationSeverities(p.evaluate(weekStart, "", with)) {
+		if prev, ok := before[key]; !ok || severity > prev {
+			return false
+		}
+	}
+	return true
+}
+
+// violationSeverities adds up the severity of violations of the same rule on
+// the same shift or week.
+func violationSeverities(violations []Violation) map[string]float64 {
+	severities := make(map[string]float64, len(violations))
+	for _, v := range violations {
+		key := v.Code + "::" + v.Date
+		if v.From != nil {
+			key += "::" + v.From.Format(time.RFC3339)
+		}
+		severities[key] += v.severity
+	}
+	return severities
+}
@@ -0,0 +1,149 @@
+package business
+
+import (
+	"slices"
+	"testing"
+)
+
+// work returns the shift from from to to starting dayIdx days into testWeek.
+func work(dayIdx int, from string, to string) workShift {
+	start, end := shiftInterval(testWeek.AddDate(0, 0, dayIdx), clock(from), clock(to))
+	return workShift{start: start, end: end}
+}
+
+func TestRuleProfileEvaluate(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile RuleProfile
+		shifts  []workShift
+		want    []string
+	}{
+		{
+			name:    "compliant week",
+			profile: DefaultRuleProfile,
+			shifts:  []workShift{work(0, "09:00", "17:00"), work(1, "09:00", "17:00"), work(2, "09:00", "17:00")},
+		},
+		{
+			name:    "short daily rest",
+			profile: RuleProfile{MinDailyRestHours: 11},
+			shifts:  []workShift{work(0, "14:00", "22:00"), work(1, "06:00", "14:00")},
+			want:    []string{ViolationDailyRest},
+		},
+		{
+			name:    "split shifts on the same day",
+			profile: RuleProfile{MinDailyRestHours: 11},
+			shifts:  []workShift{work(0, "08:00", "11:00"), work(0, "16:00", "20:00")},
+		},
+		{
+			name:    "daily rest across the week boundary",
+			profile: RuleProfile{MinDailyRestHours: 11},
+			shifts:  []workShift{work(-1, "14:00", "23:00"), work(0, "06:00", "14:00")},
+			want:    []string{ViolationDailyRest},
+		},
+		{
+			name:    "short weekly rest",
+			profile: RuleProfile{MinWeeklyRestHours: 36},
+			shifts: []workShift{
+				work(0, "09:00", "17:00"), work(1, "09:00", "17:00"), work(2, "09:00", "17:00"),
+				work(3, "09:00", "17:00"), work(4, "09:00", "17:00"), work(5, "09:00", "17:00"),
+				work(6, "09:00", "17:00"),
+			},
+			want: []string{ViolationWeeklyRest},
+		},
+		{
+			name:    "too many weekly hours",
+			profile: RuleProfile{MaxWeeklyHours: 20},
+			shifts:  []workShift{work(0, "08:00", "20:00"), work(1, "08:00", "20:00")},
+			want:    []string{ViolationWeeklyHours},
+		},
+		{
+			name:    "overnight shift counts in full",
+			profile: RuleProfile{MaxWeeklyHours: 8},
+			shifts:  []workShift{work(6, "20:00", "06:00")},
+			want:    []string{ViolationWeeklyHours},
+		},
+		{
+			name:    "consecutive days into the next week",
+			profile: RuleProfile{MaxConsecutiveDays: 3},
+			shifts:  []workShift{work(5, "09:00", "17:00"), work(6, "09:00", "17:00"), work(7, "09:00", "17:00"), work(8, "09:00", "17:00")},
+			want:    []string{ViolationConsecutiveDays},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			for _, v := range tc.profile.evaluate(testWeek, "a@x.se", tc.shifts) {
+				got = append(got, v.Code)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRuleProfileAllows(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile RuleProfile
+		shifts  []workShift
+		add     workShift
+		want    bool
+	}{
+		{
+			name:    "compliant",
+			profile: DefaultRuleProfile,
+			shifts:  []workShift{work(0, "09:00", "17:00")},
+			add:     work(1, "09:00", "17:00"),
+			want:    true,
+		},
+		{
+			name:    "new violation",
+			profile: RuleProfile{MinDailyRestHours: 11},
+			shifts:  []workShift{work(0, "14:00", "22:00")},
+			add:     work(1, "06:00", "14:00"),
+		},
+		{
+			name:    "existing violation left alone",
+			profile: RuleProfile{MinDailyRestHours: 11},
+			shifts:  []workShift{work(0, "14:00", "22:00"), work(1, "06:00", "14:00")},
+			add:     work(3, "09:00", "17:00"),
+			want:    true,
+		},
+		{
+			name:    "existing daily rest violation made worse",
+			profile: RuleProfile{MinDailyRestHours: 11},
+			shifts:  []workShift{work(0, "14:00", "22:00"), work(1, "08:00", "14:00")},
+			add:     work(0, "22:00", "02:00"),
+		},
+		{
+			name:    "existing weekly hours violation made worse",
+			profile: RuleProfile{MaxWeeklyHours: 10},
+			shifts:  []workShift{work(0, "08:00", "20:00")},
+			add:     work(2, "09:00", "17:00"),
+		},
+		{
+			name:    "existing run of days made longer",
+			profile: RuleProfile{MaxConsecutiveDays: 2},
+			shifts:  []workShift{work(0, "09:00", "17:00"), work(1, "09:00", "17:00"), work(2, "09:00", "17:00")},
+			add:     work(3, "09:00", "17:00"),
+		},
+		{
+			name:    "separate run of days",
+			profile: RuleProfile{MaxConsecutiveDays: 2},
+			shifts:  []workShift{work(0, "09:00", "17:00"), work(1, "09:00", "17:00"), work(2, "09:00", "17:00")},
+			add:     work(5, "09:00", "17:00"),
+			want:    true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sortWorkShifts(tc.shifts)
+			if got := tc.profile.allows(testWeek, tc.shifts, tc.add); got != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
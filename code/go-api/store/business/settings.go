@@ -0,0 +1,92 @@
+package business
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+const (
+	SettingsKey     = "business-settings"
+	DefaultTimezone = "Europe/Stockholm"
+	DefaultCurrency = "SEK"
+)
+
+type Settings struct {
+	// Timezone is the IANA name of the time zone dates and shift times are
+	// expressed in.
+	Timezone string `json:"timezone"`
+	// Currency is the ISO 4217 code of the currency wages and budgets are
+	// expressed in.
+	Currency string `json:"currency"`
+	// RequireSwapApproval makes accepted shift swaps wait for a manager to
+	// approve them before the schedule is changed.
+	RequireSwapApproval bool `json:"requireSwapApproval"`
+	// RequireClaimApproval makes claims on open shifts wait for a manager to
+	// approve them. Otherwise the first employee to claim an open place gets
+	// it.
+	RequireClaimApproval bool `json:"requireClaimApproval"`
+}
+
+func defaultSettings() Settings {
+	return Settings{
+		Timezone: DefaultTimezone,
+		Currency: DefaultCurrency,
+	}
+}
+
+func (bs *BusinessStore) GetSettings(ctx context.Context) (Settings, error) {
+	res, err := bs.configCol.Get(SettingsKey, &gocb.GetOptions{
+		Context: ctx,
+	})
+	if errors.Is(err, gocb.ErrDocumentNotFound) {
+		return defaultSettings(), nil
+	}
+	if err != nil {
+		return Settings{}, err
+	}
+
+	var s Settings
+	err = res.Content(&s)
+	if s.Currency == "" {
+		s.Currency = DefaultCurrency
+	}
+	return s, err
+}
+
+func (bs *BusinessStore) SetSettings(ctx context.Context, s Settings) error {
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return err
+	}
+
+	err = bs.SetConfig(ctx, SettingsKey, s)
+	if err != nil {
+		return err
+	}
+
+	bs.tz.Set(loc)
+	return nil
+}
+
+// Location is the time zone of the business.
+func (bs *BusinessStore) Location() *time.Location {
+	return bs.tz.Location()
+}
+
+func (bs *BusinessStore) loadTimeZone(ctx context.Context) error {
+	s, err := bs.GetSettings(ctx)
+	if err != nil {
+		return err
+	}
+
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return err
+	}
+
+	bs.tz.Set(loc)
+	return nil
+}
@@ -0,0 +1,264 @@
+package business
+
+import (
+	"airdock/store"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+const (
+	SupplementSettingsKey = "supplement-settings"
+)
+
+// SupplementRule pays extra for work within a time band, e.g. evenings on
+// weekdays or all of Sunday. A rule without Weekdays or Holidays applies
+// every day.
+type SupplementRule struct {
+	Name     string      `json:"name"`
+	From     store.Clock `json:"from"`
+	To       store.Clock `json:"to"`
+	Weekdays []string    `json:"weekdays,omitempty"`
+	Holidays bool        `json:"holidays"`
+	Percent  float64     `json:"percent,omitempty"`
+	Amount   int64       `json:"amount,omitempty"`
+}
+
+// startsOn reports whether the band of the rule starts on date.
+func (r SupplementRule) startsOn(date time.Time, holiday bool) bool {
+	if len(r.Weekdays) == 0 && !r.Holidays {
+		return true
+	}
+	if r.Holidays && holiday {
+		return true
+	}
+	return slices.Contains(r.Weekdays, strings.ToLower(date.Weekday().String()))
+}
+
+// rate returns the supplement of an hour of work at an hourly wage.
+func (r SupplementRule) rate(wage int64) int64 {
+	if r.Amount != 0 {
+		return r.Amount
+	}
+	return int64(math.Round(float64(wage) * r.Percent / 100))
+}
+
+// SupplementSettings holds the supplement rules of every collective agreement
+// of the business, keyed by agreement name. Employees without an agreement,
+// or with an unknown one, are paid by Default.
+type SupplementSettings struct {
+	Default    string                      `json:"default"`
+	Agreements map[string][]SupplementRule `json:"agreements"`
+}
+
+func defaultSupplementSettings() SupplementSettings {
+	return SupplementSettings{
+		Default: "default",
+		Agreements: map[string][]SupplementRule{
+			"default": {},
+		},
+	}
+}
+
+// HasAgreement reports whether name is an agreement of the settings. The
+// empty name stands for the default agreement.
+func (ss SupplementSettings) HasAgreement(name string) bool {
+	if name == "" {
+		return true
+	}
+	_, ok := ss.Agreements[name]
+	return ok
+}
+
+func (ss SupplementSettings) rules(e store.Employee) []SupplementRule {
+	if rules, ok := ss.Agreements[e.Agreement]; ok {
+		return rules
+	}
+	return ss.Agreements[ss.Default]
+}
+
+func (bs *BusinessStore) GetSupplementSettings(ctx context.Context) (SupplementSettings, error) {
+	res, err := bs.configCol.Get(SupplementSettingsKey, &gocb.GetOptions{
+		Context: ctx,
+	})
+	if errors.Is(err, gocb.ErrDocumentNotFound) {
+		return defaultSupplementSettings(), nil
+	}
+	if err != nil {
+		return SupplementSettings{}, err
+	}
+
+	var ss SupplementSettings
+	err = res.Content(&ss)
+	return ss, err
+}
+
+// validate checks that the default agreement exists and that every rule pays
+// either a percent or an amount and starts on lowercase weekday names.
+func (ss SupplementSettings) validate() error {
+	if _, ok := ss.Agreements[ss.Default]; !ok {
+		return fmt.Errorf("default agreement %q does not exist", ss.Default)
+	}
+	for name, rules := range ss.Agreements {
+		for _, r := range rules {
+			if (r.Percent != 0) == (r.Amount != 0) {
+				return fmt.Errorf("rule %q of agreement %q needs either a percent or an amount", r.Name, name)
+			}
+			if r.Percent < 0 || r.Amount < 0 {
+				return fmt.Errorf("rule %q of agreement %q must not be negative", r.Name, name)
+			}
+			for _, weekday := range r.Weekdays {
+				if !slices.ContainsFunc(Weekdays, func(d time.Weekday) bool {
+					return strings.ToLower(d.String()) == weekday
+				}) {
+					return fmt.Errorf("rule %q of agreement %q has unknown weekday %q", r.Name, name, weekday)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (bs *BusinessStore) SetSupplementSettings(ctx context.Context, ss SupplementSettings) error {
+	if err := ss.validate(); err != nil {
+		return err
+	}
+	return bs.SetConfig(ctx, SupplementSettingsKey, ss)
+}
+
+// SupplementPay is what a supplement rule adds to the pay of some work.
+type SupplementPay struct {
+	Rule    string `json:"rule"`
+	Minutes int    `json:"minutes"`
+	Amount  int64  `json:"amount"`
+}
+
+// Pay is what an employee earns for a stretch of work: the hourly wage for
+// every minute plus supplements. Amounts are in minor units of the business
+// currency.
+type Pay struct {
+	Minutes     int             `json:"minutes"`
+	Base        int64           `json:"base"`
+	Supplements []SupplementPay `json:"supplements"`
+	Total       int64           `json:"total"`
+}
+
+// PayCalculator works out pay from the supplement rules and the holidays of a
+// period.
+type PayCalculator struct {
+	settings SupplementSettings
+	holidays map[string]bool
+}
+
+func NewPayCalculator(ss SupplementSettings, holidays []Holiday) PayCalculator {
+	pc := PayCalculator{
+		settings: ss,
+		holidays: make(map[string]bool, len(holidays)),
+	}
+	for _, h := range holidays {
+		pc.holidays[h.Date] = true
+	}
+	return pc
+}
+
+// PayCalculator returns a calculator for work between from and to. Holidays
+// are loaded a day beyond both ends, for bands that cross midnight.
+func (bs *BusinessStore) PayCalculator(ctx context.Context, from time.Time, to time.Time) (PayCalculator, error) {
+	ss, err := bs.GetSupplementSettings(ctx)
+	if err != nil {
+		return PayCalculator{}, err
+	}
+	holidays, err := bs.GetHolidays(ctx, from.AddDate(0, 0, -1), to.AddDate(0, 0, 1))
+	if err != nil {
+		return PayCalculator{}, err
+	}
+	return NewPayCalculator(ss, holidays), nil
+}
+
+type supplementBand struct {
+	rule  int
+	start time.Time
+	end   time.Time
+}
+
+// Pay returns what e earns for working from start to end. Where the bands of
+// several rules overlap only the rule paying the most applies, so an evening
+// on a holiday pays the holiday supplement rather than both.
+func (pc PayCalculator) Pay(e store.Employee, start time.Time, end time.Time) Pay {
+	minutes := int(end.Sub(start).Minutes())
+	p := Pay{
+		Minutes:     minutes,
+		Base:        wageCost(e.HourlyWage, minutes),
+		Supplements: []SupplementPay{},
+	}
+	p.Total = p.Base
+	rules := pc.settings.rules(e)
+	if len(rules) == 0 || !end.After(start) {
+		return p
+	}
+
+	// bands starting the day before may reach into the work
+	var bands []supplementBand
+	bounds := []time.Time{start, end}
+	for date := dayOf(start).AddDate(0, 0, -1); !date.After(end); date = date.AddDate(0, 0, 1) {
+		holiday := pc.holidays[date.Format(time.DateOnly)]
+		for i, r := range rules {
+			if !r.startsOn(date, holiday) {
+				continue
+			}
+			bandStart, bandEnd := shiftInterval(date, r.From, r.To)
+			if bandStart.Before(start) {
+				bandStart = start
+			}
+			if bandEnd.After(end) {
+				bandEnd = end
+			}
+			if !bandEnd.After(bandStart) {
+				continue
+			}
+			bands = append(bands, supplementBand{rule: i, start: bandStart, end: bandEnd})
+			bounds = append(bounds, bandStart, bandEnd)
+		}
+	}
+	slices.SortFunc(bounds, func(a, b time.Time) int {
+		return a.Compare(b)
+	})
+	bounds = slices.CompactFunc(bounds, time.Time.Equal)
+
+	supplemented := make([]int, len(rules))
+	for i := 1; i < len(bounds); i++ {
+		from, to := bounds[i-1], bounds[i]
+		best := -1
+		for _, b := range bands {
+			if b.start.After(from) || b.end.Before(to) {
+				continue
+			}
+			if best == -1 || rules[b.rule].rate(e.HourlyWage) > rules[best].rate(e.HourlyWage) {
+				best = b.rule
+			}
+		}
+		if best != -1 {
+			supplemented[best] += int(to.Sub(from).Minutes())
+		}
+	}
+
+	for i, r := range rules {
+		if supplemented[i] == 0 {
+			continue
+		}
+		sp := SupplementPay{
+			Rule:    r.Name,
+			Minutes: supplemented[i],
+			Amount:  wageCost(r.rate(e.HourlyWage), supplemented[i]),
+		}
+		p.Supplements = append(p.Supplements, sp)
+		p.Total += sp.Amount
+	}
+	return p
+}
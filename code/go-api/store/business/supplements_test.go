@@ -0,0 +1,160 @@
+package business
+
+import (
+	"airdock/store"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPayCalculatorPay(t *testing.T) {
+	weekdays := []string{"monday", "tuesday", "wednesday", "thursday", "friday"}
+	ss := SupplementSettings{
+		Default: "default",
+		Agreements: map[string][]SupplementRule{
+			"default": {
+				{Name: "evening", From: clock("18:00"), To: clock("22:00"), Weekdays: weekdays, Percent: 20},
+				{Name: "night", From: clock("22:00"), To: clock("06:00"), Percent: 40},
+				{Name: "sunday", From: clock("00:00"), To: clock("00:00"), Weekdays: []string{"sunday"}, Amount: 5000},
+				{Name: "holiday", From: clock("00:00"), To: clock("00:00"), Holidays: true, Percent: 100},
+			},
+			"none": {},
+		},
+	}
+	pc := NewPayCalculator(ss, []Holiday{{ID: "christmas-day", Date: "2026-12-25"}})
+	employee := store.Employee{Email: "a@x.se", HourlyWage: 20000}
+
+	at := func(s string) time.Time {
+		t, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+
+	tests := []struct {
+		name      string
+		agreement string
+		start     string
+		end       string
+		want      Pay
+	}{
+		{
+			name:  "no supplements",
+			start: "2026-10-12 09:00",
+			end:   "2026-10-12 17:00",
+			want:  Pay{Minutes: 480, Base: 160000, Supplements: []SupplementPay{}, Total: 160000},
+		},
+		{
+			name:  "evening into the night",
+			start: "2026-10-16 16:00",
+			end:   "2026-10-17 02:00",
+			want: Pay{Minutes: 600, Base: 200000, Supplements: []SupplementPay{
+				{Rule: "evening", Minutes: 240, Amount: 16000},
+				{Rule: "night", Minutes: 240, Amount: 32000},
+			}, Total: 248000},
+		},
+		{
+			name:  "night band of the day before",
+			start: "2026-10-17 22:00",
+			end:   "2026-10-18 06:00",
+			want: Pay{Minutes: 480, Base: 160000, Supplements: []SupplementPay{
+				{Rule: "night", Minutes: 480, Amount: 64000},
+			}, Total: 224000},
+		},
+		{
+			name:  "fixed amount",
+			start: "2026-10-18 08:00",
+			end:   "2026-10-18 16:00",
+			want: Pay{Minutes: 480, Base: 160000, Supplements: []SupplementPay{
+				{Rule: "sunday", Minutes: 480, Amount: 40000},
+			}, Total: 200000},
+		},
+		{
+			name:  "holiday pays instead of overlapping bands",
+			start: "2026-12-24 20:00",
+			end:   "2026-12-25 04:00",
+			want: Pay{Minutes: 480, Base: 160000, Supplements: []SupplementPay{
+				{Rule: "evening", Minutes: 120, Amount: 8000},
+				{Rule: "night", Minutes: 120, Amount: 16000},
+				{Rule: "holiday", Minutes: 240, Amount: 80000},
+			}, Total: 264000},
+		},
+		{
+			name:      "agreement without rules",
+			agreement: "none",
+			start:     "2026-10-16 16:00",
+			end:       "2026-10-17 02:00",
+			want:      Pay{Minutes: 600, Base: 200000, Supplements: []SupplementPay{}, Total: 200000},
+		},
+		{
+			name:      "unknown agreement pays by default",
+			agreement: "retail",
+			start:     "2026-10-18 08:00",
+			end:       "2026-10-18 16:00",
+			want: Pay{Minutes: 480, Base: 160000, Supplements: []SupplementPay{
+				{Rule: "sunday", Minutes: 480, Amount: 40000},
+			}, Total: 200000},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			e := employee
+			e.Agreement = tc.agreement
+			got := pc.Pay(e, at(tc.start), at(tc.end))
+			if got.Minutes != tc.want.Minutes || got.Base != tc.want.Base || got.Total != tc.want.Total {
+				t.Errorf("got %d minutes, base %d, total %d, want %d minutes, base %d, total %d",
+					got.Minutes, got.Base, got.Total, tc.want.Minutes, tc.want.Base, tc.want.Total)
+			}
+			if !slices.Equal(got.Supplements, tc.want.Supplements) {
+				t.Errorf("supplements: got %v, want %v", got.Supplements, tc.want.Supplements)
+			}
+		})
+	}
+}
+
+func TestSupplementSettingsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    SupplementRule
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			rule: SupplementRule{Name: "weekend", Weekdays: []string{"saturday", "sunday"}, Percent: 50},
+		},
+		{
+			name:    "capitalized weekday",
+			rule:    SupplementRule{Name: "weekend", Weekdays: []string{"Saturday"}, Percent: 50},
+			wantErr: true,
+		},
+		{
+			name:    "unknown weekday",
+			rule:    SupplementRule{Name: "weekend", Weekdays: []string{"sat"}, Percent: 50},
+			wantErr: true,
+		},
+		{
+			name:    "percent and amount",
+			rule:    SupplementRule{Name: "weekend", Percent: 50, Amount: 1000},
+			wantErr: true,
+		},
+		{
+			name:    "negative",
+			rule:    SupplementRule{Name: "weekend", Percent: -50},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ss := SupplementSettings{
+				Default:    "default",
+				Agreements: map[string][]SupplementRule{"default": {tc.rule}},
+			}
+			if err := ss.validate(); (err != nil) != tc.wantErr {
+				t.Errorf("got error %v, want error %v", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,388 @@
+package business
+
+import (
+	"airdock/store"
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	SwapOpen            = "open"
+	SwapPendingApproval = "pending_approval"
+	SwapApplied         = "applied"
+	SwapRejected        = "rejected"
+	SwapCancelled       = "cancelled"
+)
+
+const (
+	VersionSwapped = "swapped"
+)
+
+var (
+	ErrSwapNotFound  = gocb.ErrDocumentNotFound
+	ErrSwapClosed    = errors.New("swap request is no longer open")
+	ErrSwapChanged   = errors.New("swap request was changed at the same time, try again")
+	ErrNotAssigned   = errors.New("employee is not assigned to the shift")
+	ErrNotSwapTarget = errors.New("swap request is offered to another employee")
+	ErrOwnSwap       = errors.New("employees cannot accept their own swap request")
+	// ErrAlreadyAssigned is returned when the employee taking over a place
+	// on a shift already works the shift.
+	ErrAlreadyAssigned = errors.New("employee is already assigned to the shift")
+)
+
+// SwapRequest is an employee offering their place on a shift to a colleague.
+// Once accepted, and approved by a manager if the business requires it, the
+// colleague takes over the place in the schedule.
+type SwapRequest struct {
+	ID      string      `json:"id"`
+	Week    string      `json:"week"`
+	Date    string      `json:"date"`
+	From    store.Clock `json:"from"`
+	To      store.Clock `json:"to"`
+	Offerer string      `json:"offerer"`
+	// Target is the only colleague who may accept, if set.
+	Target     string     `json:"target,omitempty"`
+	Acceptor   string     `json:"acceptor,omitempty"`
+	Note       string     `json:"note,omitempty"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	AcceptedAt *time.Time `json:"acceptedAt,omitempty"`
+	DecidedBy  string     `json:"decidedBy,omitempty"`
+	DecidedAt  *time.Time `json:"decidedAt,omitempty"`
+	// Version is the schedule version the swap was applied in.
+	Version int `json:"version,omitempty"`
+}
+
+func (sr SwapRequest) date(loc *time.Location) (time.Time, error) {
+	return time.ParseInLocation(time.DateOnly, sr.Date, loc)
+}
+
+// CreateSwap offers the place of sr.Offerer on the shift given by sr.Date,
+// sr.From and sr.To. The offerer must be assigned to the shift.
+func (bs *BusinessStore) CreateSwap(ctx context.Context, sr SwapRequest) (SwapRequest, error) {
+	date, err := sr.date(bs.Location())
+	if err != nil {
+		return SwapRequest{}, err
+	}
+
+	status, err := bs.GetScheduleStatus(ctx, date)
+	if err != nil {
+		return SwapRequest{}, err
+	}
+	if status.Status == StatusLocked {
+		return SwapRequest{}, ErrScheduleLocked
+	}
+
+	ws, err := bs.GetScheduleForWeek(ctx, date)
+	if err != nil {
+		return SwapRequest{}, err
+	}
+	s, ok := findScheduledShift(ws.Day(date.Weekday()).Shifts, sr.From, sr.To)
+	if !ok || !slices.Contains(s.Employees, sr.Offerer) {
+		return SwapRequest{}, ErrNotAssigned
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return SwapRequest{}, err
+	}
+	sr.ID = id.String()
+	sr.Week = store.StartOfWeek(date).Format(time.DateOnly)
+	sr.Acceptor = ""
+	sr.Status = SwapOpen
+	sr.CreatedAt = time.Now().In(bs.Location())
+
+	_, err = bs.swapCol.Insert(sr.ID, sr, &gocb.InsertOptions{
+		Context: ctx,
+	})
+	return sr, err
+}
+
+func (bs *BusinessStore) GetSwap(ctx context.Context, id string) (SwapRequest, error) {
+	sr, _, err := bs.getSwapForUpdate(ctx, id)
+	return sr, err
+}
+
+// getSwapForUpdate returns the swap request and the CAS to replace it with.
+func (bs *BusinessStore) getSwapForUpdate(ctx context.Context, id string) (SwapRequest, gocb.Cas, error) {
+	res, err := bs.swapCol.Get(id, &gocb.GetOptions{
+		Context: ctx,
+	})
+	if err != nil {
+		return SwapRequest{}, 0, err
+	}
+
+	var sr SwapRequest
+	err = res.Content(&sr)
+	return sr, res.Cas(), err
+}
+
+// replaceSwap writes sr if it has not changed since it was read with cas, and
+// returns ErrSwapChanged otherwise.
+func (bs *BusinessStore) replaceSwap(ctx context.Context, sr SwapRequest, cas gocb.Cas) (gocb.Cas, error) {
+	res, err := bs.swapCol.Replace(sr.ID, sr, &gocb.ReplaceOptions{
+		Context: ctx,
+		Cas:     cas,
+	})
+	if errors.Is(err, gocb.ErrCasMismatch) {
+		return 0, ErrSwapChanged
+	}
+	if err != nil {
+		return 0, err
+	}
+	return res.Cas(), nil
+}
+
+// Swaps returns the swap requests of the week containing week, oldest first.
+func (bs *BusinessStore) Swaps(ctx context.Context, week time.Time) ([]SwapRequest, error) {
+	res, err := bs.scope.Query(
+		"SELECT x.* FROM swaps x WHERE x.week = $week ORDER BY x.createdAt",
+		&gocb.QueryOptions{
+			Context: ctx,
+			NamedParameters: map[string]interface{}{
+				"week": store.StartOfWeek(week).Format(time.DateOnly),
+			},
+			ScanConsistency: gocb.QueryScanConsistencyRequestPlus,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close() // ignore error
+
+	swaps := []SwapRequest{}
+	for res.Next() {
+		var sr SwapRequest
+		err := res.Row(&sr)
+		if err != nil {
+			return nil, err
+		}
+		swaps = append(swaps, sr)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+	}
+
+	return swaps, res.Err()
+}
+
+// AcceptSwap lets acceptor take over the offered place. Swaps breaking the
+// rules return the violations; the others are applied right away unless the
+// business requires swaps to be approved.
+func (bs *BusinessStore) AcceptSwap(
+	ctx context.Context,
+	id string,
+	acceptor string,
+	employees []store.Employee,
+	ava map[string]store.WeekAvailability,
+) (SwapRequest, []Violation, error) {
+	sr, cas, err := bs.getSwapForUpdate(ctx, id)
+	if err != nil {
+		return SwapRequest{}, nil, err
+	}
+	if sr.Status != SwapOpen {
+		return SwapRequest{}, nil, ErrSwapClosed
+	}
+	if sr.Target != "" && sr.Target != acceptor {
+		return SwapRequest{}, nil, ErrNotSwapTarget
+	}
+	if sr.Offerer == acceptor {
+		return SwapRequest{}, nil, ErrOwnSwap
+	}
+
+	sr.Acceptor = acceptor
+	violations, err := bs.checkSwap(ctx, sr, employees, ava)
+	if err != nil || len(violations) > 0 {
+		return SwapRequest{}, violations, err
+	}
+
+	now := time.Now().In(bs.Location())
+	sr.AcceptedAt = &now
+
+	settings, err := bs.GetSettings(ctx)
+	if err != nil {
+		return SwapRequest{}, nil, err
+	}
+	if settings.RequireSwapApproval {
+		sr.Status = SwapPendingApproval
+		_, err = bs.replaceSwap(ctx, sr, cas)
+		return sr, nil, err
+	}
+
+	sr, err = bs.applySwap(ctx, sr, cas, acceptor)
+	return sr, nil, err
+}
+
+// DecideSwap approves or rejects an accepted swap request. An approved swap is
+// checked again, as the schedule may have changed since it was accepted.
+func (bs *BusinessStore) DecideSwap(
+	ctx context.Context,
+	id string,
+	approve bool,
+	actor string,
+	employees []store.Employee,
+	ava map[string]store.WeekAvailability,
+) (SwapRequest, []Violation, error) {
+	sr, cas, err := bs.getSwapForUpdate(ctx, id)
+	if err != nil {
+		return SwapRequest{}, nil, err
+	}
+	if sr.Status != SwapPendingApproval {
+		return SwapRequest{}, nil, ErrSwapClosed
+	}
+
+	now := time.Now().In(bs.Location())
+	sr.DecidedBy = actor
+	sr.DecidedAt = &now
+	if !approve {
+		sr.Status = SwapRejected
+		_, err = bs.replaceSwap(ctx, sr, cas)
+		return sr, nil, err
+	}
+
+	violations, err := bs.checkSwap(ctx, sr, employees, ava)
+	if err != nil || len(violations) > 0 {
+		return SwapRequest{}, violations, err
+	}
+
+	sr, err = bs.applySwap(ctx, sr, cas, actor)
+	return sr, nil, err
+}
+
+// CancelSwap withdraws a swap request that has not been applied yet.
+func (bs *BusinessStore) CancelSwap(ctx context.Context, id string) (SwapRequest, error) {
+	sr, cas, err := bs.getSwapForUpdate(ctx, id)
+	if err != nil {
+		return SwapRequest{}, err
+	}
+	if sr.Status != SwapOpen && sr.Status != SwapPendingApproval {
+		return SwapRequest{}, ErrSwapClosed
+	}
+
+	sr.Status = SwapCancelled
+	_, err = bs.replaceSwap(ctx, sr, cas)
+	return sr, err
+}
+
+// checkSwap returns the violations the acceptor of sr would cause by taking
+// over the shift in the current schedule.
+func (bs *BusinessStore) checkSwap(
+	ctx context.Context,
+	sr SwapRequest,
+	employees []store.Employee,
+	ava map[string]store.WeekAvailability,
+) ([]Violation, error) {
+	date, err := sr.date(bs.Location())
+	if err != nil {
+		return nil, err
+	}
+
+	ws, err := bs.GetScheduleForWeek(ctx, date)
+	if err != nil {
+		return nil, err
+	}
+	ws, err = swapEmployee(ws, date.Weekday(), sr.From, sr.To, sr.Offerer, sr.Acceptor)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := bs.ValidateScheduleForWeek(ctx, date, ws, employees, ava)
+	if err != nil {
+		return nil, err
+	}
+
+	return violationsOf(res.Violations, sr.Acceptor), nil
+}
+
+// applySwap hands the place of the offerer over to the acceptor as a new
+// version written by actor. The swap is marked applied first and reopened on
+// failure.
+func (bs *BusinessStore) applySwap(ctx context.Context, sr SwapRequest, cas gocb.Cas, actor string) (SwapRequest, error) {
+	date, err := sr.date(bs.Location())
+	if err != nil {
+		return SwapRequest{}, err
+	}
+
+	prevStatus := sr.Status
+	sr.Status = SwapApplied
+	cas, err = bs.replaceSwap(ctx, sr, cas)
+	if err != nil {
+		return SwapRequest{}, err
+	}
+
+	sv, err := bs.referencedVersion(ctx, date, VersionSwapped, sr.ID)
+	if errors.Is(err, ErrVersionNotFound) {
+		sv, err = bs.updateSchedule(ctx, date, ScheduleVersion{
+			Action:    VersionSwapped,
+			Actor:     actor,
+			Reference: sr.ID,
+		}, func(prev WeekSchedule) (WeekSchedule, error) {
+			return swapEmployee(prev, date.Weekday(), sr.From, sr.To, sr.Offerer, sr.Acceptor)
+		})
+	}
+	if err != nil {
+		sr.Status = prevStatus
+		if _, rerr := bs.replaceSwap(ctx, sr, cas); rerr != nil {
+			bs.logger.Warn("failed to reopen swap request", "id", sr.ID, "err", rerr)
+		}
+		return SwapRequest{}, err
+	}
+
+	sr.Version = sv.Version
+	_, err = bs.replaceSwap(ctx, sr, cas)
+	return sr, err
+}
+
+// swapEmployee returns a copy of ws in which to has taken the place of from on
+// the shift, including any role from worked.
+func swapEmployee(ws WeekSchedule, weekday time.Weekday, start store.Clock, end store.Clock, from string, to string) (WeekSchedule, error) {
+	ds := ws.Day(weekday)
+	shifts := make([]ShiftSchedule, len(ds.Shifts))
+	copy(shifts, ds.Shifts)
+
+	for i, s := range shifts {
+		if s.From != start || s.To != end {
+			continue
+		}
+		if !slices.Contains(s.Employees, from) {
+			return WeekSchedule{}, ErrNotAssigned
+		}
+		if slices.Contains(s.Employees, to) {
+			return WeekSchedule{}, ErrAlreadyAssigned
+		}
+
+		s.Employees = replaceEmail(s.Employees, from, to)
+		if s.Roles != nil {
+			roles := make(map[string][]string, len(s.Roles))
+			for role, emails := range s.Roles {
+				roles[role] = replaceEmail(emails, from, to)
+			}
+			s.Roles = roles
+		}
+		shifts[i] = s
+
+		ds.Shifts = shifts
+		ws.SetDay(weekday, ds)
+		return ws, nil
+	}
+	return WeekSchedule{}, ErrNotAssigned
+}
+
+func replaceEmail(emails []string, from string, to string) []string {
+	replaced := make([]string, len(emails))
+	for i, email := range emails {
+		if email == from {
+			email = to
+		}
+		replaced[i] = email
+	}
+	return replaced
+}
@@ -0,0 +1,241 @@
+package business
+
+import (
+	"airdock/store"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+const (
+	TemplateAssignmentsKey = "template-assignments"
+)
+
+var (
+	ErrTemplateNotFound = gocb.ErrDocumentNotFound
+	ErrTemplateExists   = gocb.ErrDocumentExists
+	ErrTemplateInUse    = errors.New("template is assigned to a date range")
+)
+
+// TimetableTemplate is a named timetable that can be assigned to date
+// ranges. If Cycle is set, it rotates through its weeks starting on Anchor.
+type TimetableTemplate struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Timetable   WeekTimetable   `json:"timetable"`
+	Cycle       []WeekTimetable `json:"cycle,omitempty"`
+	Anchor      string          `json:"anchor,omitempty"`
+}
+
+// CycleWeek returns the index into Cycle of the week starting at weekStart,
+// or -1 if the template does not rotate.
+func (t TimetableTemplate) CycleWeek(weekStart time.Time) (int, error) {
+	if len(t.Cycle) == 0 {
+		return -1, nil
+	}
+	anchor, err := time.Parse(time.DateOnly, t.Anchor)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cycle anchor: %w", err)
+	}
+
+	// count calendar days rather than hours, which differ across daylight
+	// saving time changes
+	y, m, d := weekStart.Date()
+	weeks := int(time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Sub(anchor).Hours()) / 24 / 7
+	n := len(t.Cycle)
+	return (weeks%n + n) % n, nil
+}
+
+// Week returns the timetable of the week starting at weekStart.
+func (t TimetableTemplate) Week(weekStart time.Time) (WeekTimetable, error) {
+	i, err := t.CycleWeek(weekStart)
+	if err != nil {
+		return WeekTimetable{}, err
+	}
+	if i >= 0 {
+		return t.Cycle[i], nil
+	}
+	return t.Timetable, nil
+}
+
+// TemplateAssignment applies a template to every week whose Monday lies
+// between From and To, both inclusive. An empty To means the assignment has
+// no end. Dates are formatted as 2006-01-02.
+type TemplateAssignment struct {
+	Template string `json:"template"`
+	From     string `json:"from"`
+	To       string `json:"to,omitempty"`
+}
+
+func (ta TemplateAssignment) covers(weekStart time.Time) bool {
+	week := weekStart.Format(time.DateOnly)
+	return week >= ta.From && (ta.To == "" || week <= ta.To)
+}
+
+func (bs *BusinessStore) Templates(ctx context.Context) ([]TimetableTemplate, error) {
+	res, err := bs.scope.Query("SELECT x.* FROM templates x ORDER BY x.name", &gocb.QueryOptions{
+		Context: ctx,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close() // ignore error
+
+	templates := []TimetableTemplate{}
+	for res.Next() {
+		var t TimetableTemplate
+		err := res.Row(&t)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+	}
+
+	return templates, res.Err()
+}
+
+func (bs *BusinessStore) GetTemplate(ctx context.Context, name string) (TimetableTemplate, error) {
+	res, err := bs.templateCol.Get(name, &gocb.GetOptions{
+		Context: ctx,
+	})
+	if err != nil {
+		return TimetableTemplate{}, err
+	}
+
+	var t TimetableTemplate
+	err = res.Content(&t)
+	return t, err
+}
+
+// SetTemplate creates or replaces a template. The anchor of a cycle is moved
+// to the Monday of its week.
+func (bs *BusinessStore) SetTemplate(ctx context.Context, t TimetableTemplate) (TimetableTemplate, error) {
+	if len(t.Cycle) > 0 {
+		anchor, err := time.Parse(time.DateOnly, t.Anchor)
+		if err != nil {
+			return TimetableTemplate{}, fmt.Errorf("invalid cycle anchor: %w", err)
+		}
+		t.Anchor = store.StartOfWeek(anchor).Format(time.DateOnly)
+		t.Timetable = WeekTimetable{}
+	} else {
+		t.Anchor = ""
+	}
+
+	_, err := bs.templateCol.Upsert(t.Name, t, &gocb.UpsertOptions{
+		Context: ctx,
+	})
+	return t, err
+}
+
+// CloneTemplate copies the template name to a new template called newName.
+func (bs *BusinessStore) CloneTemplate(ctx context.Context, name string, newName string) (TimetableTemplate, error) {
+	t, err := bs.GetTemplate(ctx, name)
+	if err != nil {
+		return TimetableTemplate{}, err
+	}
+
+	t.Name = newName
+	_, err = bs.templateCol.Insert(t.Name, t, &gocb.InsertOptions{
+		Context: ctx,
+	})
+	return t, err
+}
+
+// DeleteTemplate deletes a template unless it is still assigned.
+func (bs *BusinessStore) DeleteTemplate(ctx context.Context, name string) error {
+	assignments, err := bs.GetTemplateAssignments(ctx)
+	if err != nil {
+		return err
+	}
+	for _, ta := range assignments {
+		if ta.Template == name {
+			return ErrTemplateInUse
+		}
+	}
+
+	_, err = bs.templateCol.Remove(name, &gocb.RemoveOptions{
+		Context: ctx,
+	})
+	return err
+}
+
+func (bs *BusinessStore) GetTemplateAssignments(ctx context.Context) ([]TemplateAssignment, error) {
+	res, err := bs.configCol.Get(TemplateAssignmentsKey, &gocb.GetOptions{
+		Context: ctx,
+	})
+	if errors.Is(err, gocb.ErrDocumentNotFound) {
+		return []TemplateAssignment{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var assignments []TemplateAssignment
+	err = res.Content(&assignments)
+	return assignments, err
+}
+
+// SetTemplateAssignments replaces all template assignments. Assignments start
+// on the Monday of the week containing From. When assignments overlap, the
+// one starting last wins.
+func (bs *BusinessStore) SetTemplateAssignments(ctx context.Context, assignments []TemplateAssignment) ([]TemplateAssignment, error) {
+	normalized := make([]TemplateAssignment, 0, len(assignments))
+	for _, ta := range assignments {
+		_, err := bs.GetTemplate(ctx, ta.Template)
+		if errors.Is(err, ErrTemplateNotFound) {
+			return nil, fmt.Errorf("template %q does not exist", ta.Template)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		from, err := time.ParseInLocation(time.DateOnly, ta.From, bs.Location())
+		if err != nil {
+			return nil, err
+		}
+		ta.From = store.StartOfWeek(from).Format(time.DateOnly)
+		if ta.To != "" && ta.To < ta.From {
+			return nil, errors.New("to must not be before from")
+		}
+		normalized = append(normalized, ta)
+	}
+	sort.SliceStable(normalized, func(i, j int) bool {
+		return normalized[i].From < normalized[j].From
+	})
+
+	err := bs.SetConfig(ctx, TemplateAssignmentsKey, normalized)
+	return normalized, err
+}
+
+// templatesByName loads every template referenced by assignments. Templates
+// whose cycle cannot be resolved are an error.
+func (bs *BusinessStore) templatesByName(ctx context.Context, assignments []TemplateAssignment) (map[string]TimetableTemplate, error) {
+	templates := make(map[string]TimetableTemplate)
+	for _, ta := range assignments {
+		if _, ok := templates[ta.Template]; ok {
+			continue
+		}
+		t, err := bs.GetTemplate(ctx, ta.Template)
+		if errors.Is(err, ErrTemplateNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, err := t.CycleWeek(time.Time{}); err != nil {
+			return nil, fmt.Errorf("template %q: %w", t.Name, err)
+		}
+		templates[ta.Template] = t
+	}
+	return templates, nil
+}
@@ -0,0 +1,71 @@
+package business
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimetableTemplateCycleWeek(t *testing.T) {
+	stockholm, err := time.LoadLocation("Europe/Stockholm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cycle := []WeekTimetable{{}, {}, {}}
+
+	tests := []struct {
+		name     string
+		template TimetableTemplate
+		week     time.Time
+		want     int
+		wantErr  bool
+	}{
+		{
+			name:     "no cycle",
+			template: TimetableTemplate{},
+			week:     date("2026-10-12"),
+			want:     -1,
+		},
+		{
+			name:     "anchor week",
+			template: TimetableTemplate{Cycle: cycle, Anchor: "2026-10-12"},
+			week:     date("2026-10-12"),
+			want:     0,
+		},
+		{
+			name:     "after the anchor",
+			template: TimetableTemplate{Cycle: cycle, Anchor: "2026-10-12"},
+			week:     date("2026-11-02"),
+			want:     0,
+		},
+		{
+			name:     "before the anchor",
+			template: TimetableTemplate{Cycle: cycle, Anchor: "2026-10-12"},
+			week:     date("2026-10-05"),
+			want:     2,
+		},
+		{
+			name:     "across daylight saving time",
+			template: TimetableTemplate{Cycle: cycle, Anchor: "2026-10-19"},
+			week:     time.Date(2026, time.October, 26, 0, 0, 0, 0, stockholm),
+			want:     1,
+		},
+		{
+			name:     "invalid anchor",
+			template: TimetableTemplate{Cycle: cycle, Anchor: "19 October"},
+			week:     date("2026-10-19"),
+			wantErr:  true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.template.CycleWeek(tc.week)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tc.wantErr)
+			}
+			if err == nil && got != tc.want {
+				t.Errorf("got %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
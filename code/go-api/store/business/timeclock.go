@@ -0,0 +1,591 @@
+package business
+
+import (
+	"airdock/store"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	PunchClockIn    = "clock_in"
+	PunchClockOut   = "clock_out"
+	PunchBreakStart = "break_start"
+	PunchBreakEnd   = "break_end"
+)
+
+// clockInWindow is how long before a scheduled shift starts a clock-in is
+// taken to be for that shift.
+const clockInWindow = time.Hour
+
+var (
+	ErrTimeEntryNotFound = gocb.ErrDocumentNotFound
+	ErrInvalidPunch      = errors.New("unknown punch")
+	ErrAlreadyClockedIn  = errors.New("employee is already clocked in")
+	ErrNotClockedIn      = errors.New("employee is not clocked in")
+	ErrOnBreak           = errors.New("employee is already on a break")
+	ErrNotOnBreak        = errors.New("employee is not on a break")
+	ErrPunchOutOfOrder   = errors.New("punch is before the previous punch")
+	ErrNotScheduled      = errors.New("employee is not scheduled on the shift")
+	ErrInvalidTimeEntry  = errors.New("invalid time entry")
+	ErrTimeEntryChanged  = errors.New("time entry was changed at the same time, try again")
+)
+
+// ShiftRef identifies a shift of the schedule by the day it starts on and its
+// times.
+type ShiftRef struct {
+	Date string      `json:"date"`
+	From store.Clock `json:"from"`
+	To   store.Clock `json:"to"`
+}
+
+// Break is a break taken during a time entry. End is nil while the employee
+// is on the break.
+type Break struct {
+	Start time.Time  `json:"start"`
+	End   *time.Time `json:"end,omitempty"`
+}
+
+// TimeEntry is the work of an employee from clocking in to clocking out.
+// Shift is nil for unscheduled work and ClockOut while still at work.
+type TimeEntry struct {
+	ID       string     `json:"id"`
+	Employee string     `json:"employee"`
+	Date     string     `json:"date"`
+	Shift    *ShiftRef  `json:"shift,omitempty"`
+	ClockIn  time.Time  `json:"clockIn"`
+	ClockOut *time.Time `json:"clockOut,omitempty"`
+	Breaks   []Break    `json:"breaks"`
+	// EditedBy is the manager who last corrected the entry.
+	EditedBy string     `json:"editedBy,omitempty"`
+	EditedAt *time.Time `json:"editedAt,omitempty"`
+}
+
+func (te TimeEntry) Open() bool {
+	return te.ClockOut == nil
+}
+
+func (te TimeEntry) onBreak() bool {
+	return len(te.Breaks) > 0 && te.Breaks[len(te.Breaks)-1].End == nil
+}
+
+// last returns the time of the latest punch of the entry.
+func (te TimeEntry) last() time.Time {
+	last := te.ClockIn
+	if len(te.Breaks) > 0 {
+		b := te.Breaks[len(te.Breaks)-1]
+		last = b.Start
+		if b.End != nil {
+			last = *b.End
+		}
+	}
+	if te.ClockOut != nil {
+		last = *te.ClockOut
+	}
+	return last
+}
+
+// BreakTime returns the length of the finished breaks.
+func (te TimeEntry) BreakTime() time.Duration {
+	var d time.Duration
+	for _, b := range te.Breaks {
+		if b.End != nil {
+			d += b.End.Sub(b.Start)
+		}
+	}
+	return d
+}
+
+// Worked returns the time from clocking in to clocking out without the
+// breaks. Entries count as worked once the employee has clocked out.
+func (te TimeEntry) Worked() time.Duration {
+	if te.ClockOut == nil {
+		return 0
+	}
+	return te.ClockOut.Sub(te.ClockIn) - te.BreakTime()
+}
+
+type workSegment struct {
+	start time.Time
+	end   time.Time
+}
+
+// segments returns the stretches worked between the breaks of a finished
+// entry.
+func (te TimeEntry) segments() []workSegment {
+	if te.ClockOut == nil {
+		return nil
+	}
+	var segments []workSegment
+	start := te.ClockIn
+	for _, b := range te.Breaks {
+		if b.End == nil {
+			continue
+		}
+		if b.Start.After(start) {
+			segments = append(segments, workSegment{start: start, end: b.Start})
+		}
+		start = *b.End
+	}
+	if te.ClockOut.After(start) {
+		segments = append(segments, workSegment{start: start, end: *te.ClockOut})
+	}
+	return segments
+}
+
+// validate checks that the punches of the entry are in order and that the
+// breaks lie within it.
+func (te TimeEntry) validate() error {
+	last := te.ClockIn
+	for i, b := range te.Breaks {
+		if b.Start.Before(last) {
+			return fmt.Errorf("%w: breaks must start after the previous punch", ErrInvalidTimeEntry)
+		}
+		if b.End == nil {
+			if te.ClockOut != nil || i != len(te.Breaks)-1 {
+				return fmt.Errorf("%w: only the last break of an open entry may go on", ErrInvalidTimeEntry)
+			}
+			last = b.Start
+			continue
+		}
+		if b.End.Before(b.Start) {
+			return fmt.Errorf("%w: breaks must not end before they start", ErrInvalidTimeEntry)
+		}
+		last = *b.End
+	}
+	if te.ClockOut != nil && !te.ClockOut.After(last) {
+		return fmt.Errorf("%w: clock-out must be after the other punches", ErrInvalidTimeEntry)
+	}
+	return nil
+}
+
+// Punch records a clock-in, clock-out or the start or end of a break of an
+// employee at the given time. A clock-in without shift is matched with the
+// closest scheduled shift.
+func (bs *BusinessStore) Punch(ctx context.Context, employee string, kind string, at time.Time, shift *ShiftRef) (TimeEntry, error) {
+	at = at.In(bs.Location()).Truncate(time.Second)
+	te, cas, open, err := bs.openTimeEntry(ctx, employee)
+	if err != nil {
+		return TimeEntry{}, err
+	}
+
+	if kind == PunchClockIn {
+		if open {
+			return TimeEntry{}, ErrAlreadyClockedIn
+		}
+		return bs.clockIn(ctx, employee, at, shift)
+	}
+
+	if !open {
+		return TimeEntry{}, ErrNotClockedIn
+	}
+	if at.Before(te.last()) {
+		return TimeEntry{}, ErrPunchOutOfOrder
+	}
+	switch kind {
+	case PunchClockOut:
+		if !at.After(te.ClockIn) {
+			return TimeEntry{}, ErrPunchOutOfOrder
+		}
+		if te.onBreak() {
+			te.Breaks[len(te.Breaks)-1].End = &at
+		}
+		te.ClockOut = &at
+	case PunchBreakStart:
+		if te.onBreak() {
+			return TimeEntry{}, ErrOnBreak
+		}
+		te.Breaks = append(te.Breaks, Break{Start: at})
+	case PunchBreakEnd:
+		if !te.onBreak() {
+			return TimeEntry{}, ErrNotOnBreak
+		}
+		te.Breaks[len(te.Breaks)-1].End = &at
+	default:
+		return TimeEntry{}, ErrInvalidPunch
+	}
+
+	_, err = bs.replaceTimeEntry(ctx, te, cas)
+	if err != nil {
+		return TimeEntry{}, err
+	}
+	if kind == PunchClockOut {
+		bs.unmarkOpen(ctx, employee, 0)
+	}
+	return te, nil
+}
+
+func (bs *BusinessStore) clockIn(ctx context.Context, employee string, at time.Time, shift *ShiftRef) (TimeEntry, error) {
+	date := at.Format(time.DateOnly)
+	err := bs.checkTimesheetOpen(ctx, employee, date)
+	if err != nil {
+		return TimeEntry{}, err
+	}
+
+	if shift == nil {
+		shift, err = bs.matchShift(ctx, employee, at)
+	} else {
+		err = bs.checkScheduled(ctx, employee, *shift)
+	}
+	if err != nil {
+		return TimeEntry{}, err
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return TimeEntry{}, err
+	}
+	te := TimeEntry{
+		ID:       id.String(),
+		Employee: employee,
+		Date:     date,
+		Shift:    shift,
+		ClockIn:  at,
+		Breaks:   []Break{},
+	}
+	_, err = bs.entryCol.Insert(te.ID, te, &gocb.InsertOptions{
+		Context: ctx,
+	})
+	if err != nil {
+		return TimeEntry{}, err
+	}
+
+	err = bs.markOpen(ctx, te)
+	if err != nil {
+		_, rmErr := bs.entryCol.Remove(te.ID, &gocb.RemoveOptions{
+			Context: ctx,
+		})
+		return TimeEntry{}, errors.Join(err, rmErr)
+	}
+	return te, nil
+}
+
+// plannedShift is a shift an employee is scheduled on.
+type plannedShift struct {
+	ref   ShiftRef
+	start time.Time
+	end   time.Time
+}
+
+// plannedShifts returns the shifts employee is scheduled on that start
+// between from and to, both inclusive, ordered by their start. Only the
+// schedules employees can see are taken into account.
+func (bs *BusinessStore) plannedShifts(ctx context.Context, employee string, from time.Time, to time.Time) ([]plannedShift, error) {
+	planned := []plannedShift{}
+	for week := store.StartOfWeek(from); !week.After(to); week = week.AddDate(0, 0, 7) {
+		status, err := bs.GetScheduleStatus(ctx, week)
+		if err != nil {
+			return nil, err
+		}
+		if !status.Visible() {
+			continue
+		}
+		ws, err := bs.GetScheduleForWeek(ctx, week)
+		if errors.Is(err, ErrConfigNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for dayIdx, weekday := range Weekdays {
+			date := week.AddDate(0, 0, dayIdx)
+			if date.Before(dayOf(from)) || date.After(to) {
+				continue
+			}
+			for _, s := range ws.Day(weekday).Shifts {
+				if !slices.Contains(s.Employees, employee) {
+					continue
+				}
+				start, end := shiftInterval(date, s.From, s.To)
+				planned = append(planned, plannedShift{
+					ref: ShiftRef{
+						Date: date.Format(time.DateOnly),
+						From: s.From,
+						To:   s.To,
+					},
+					start: start,
+					end:   end,
+				})
+			}
+		}
+	}
+
+	slices.SortFunc(planned, func(a, b plannedShift) int {
+		return a.start.Compare(b.start)
+	})
+	return planned, nil
+}
+
+// matchShift returns the scheduled shift of employee starting closest to at
+// within clockInWindow that has not ended yet.
+func (bs *BusinessStore) matchShift(ctx context.Context, employee string, at time.Time) (*ShiftRef, error) {
+	planned, err := bs.plannedShifts(ctx, employee, dayOf(at).AddDate(0, 0, -1), at)
+	if err != nil {
+		return nil, err
+	}
+
+	var match *ShiftRef
+	var best time.Duration
+	for _, ps := range planned {
+		if at.Before(ps.start.Add(-clockInWindow)) || !at.Before(ps.end) {
+			continue
+		}
+		d := at.Sub(ps.start)
+		if d < 0 {
+			d = -d
+		}
+		if match == nil || d < best {
+			ref := ps.ref
+			match, best = &ref, d
+		}
+	}
+	return match, nil
+}
+
+func (bs *BusinessStore) checkScheduled(ctx context.Context, employee string, ref ShiftRef) error {
+	date, err := time.ParseInLocation(time.DateOnly, ref.Date, bs.Location())
+	if err != nil {
+		return err
+	}
+	planned, err := bs.plannedShifts(ctx, employee, date, date)
+	if err != nil {
+		return err
+	}
+	for _, ps := range planned {
+		if ps.ref == ref {
+			return nil
+		}
+	}
+	return ErrNotScheduled
+}
+
+func (bs *BusinessStore) GetTimeEntry(ctx context.Context, id string) (TimeEntry, error) {
+	te, _, err := bs.getTimeEntryForUpdate(ctx, id)
+	return te, err
+}
+
+func (bs *BusinessStore) getTimeEntryForUpdate(ctx context.Context, id string) (TimeEntry, gocb.Cas, error) {
+	res, err := bs.entryCol.Get(id, &gocb.GetOptions{
+		Context: ctx,
+	})
+	if err != nil {
+		return TimeEntry{}, 0, err
+	}
+
+	var te TimeEntry
+	err = res.Content(&te)
+	return te, res.Cas(), err
+}
+
+// replaceTimeEntry writes te if it has not changed since it was read with
+// cas, and returns ErrTimeEntryChanged otherwise.
+func (bs *BusinessStore) replaceTimeEntry(ctx context.Context, te TimeEntry, cas gocb.Cas) (gocb.Cas, error) {
+	res, err := bs.entryCol.Replace(te.ID, te, &gocb.ReplaceOptions{
+		Context: ctx,
+		Cas:     cas,
+	})
+	if errors.Is(err, gocb.ErrCasMismatch) {
+		return 0, ErrTimeEntryChanged
+	}
+	if err != nil {
+		return 0, err
+	}
+	return res.Cas(), nil
+}
+
+// openMark points to the entry an employee is clocked in on. It is stored
+// under openMarkKey next to the entries, so only one entry per employee can
+// be marked open at a time.
+type openMark struct {
+	Entry string `json:"entry"`
+}
+
+func openMarkKey(employee string) string {
+	return employee + "::open"
+}
+
+// markOpen marks te as the entry its employee is clocked in on, and returns
+// ErrAlreadyClockedIn if another entry is marked.
+func (bs *BusinessStore) markOpen(ctx context.Context, te TimeEntry) error {
+	_, err := bs.entryCol.Insert(openMarkKey(te.Employee), openMark{Entry: te.ID}, &gocb.InsertOptions{
+		Context: ctx,
+	})
+	if errors.Is(err, gocb.ErrDocumentExists) {
+		return ErrAlreadyClockedIn
+	}
+	return err
+}
+
+// unmarkOpen removes the open mark of employee, if it has not changed since
+// it was read with cas or cas is zero. A mark left behind points to a closed
+// entry and is removed by openTimeEntry.
+func (bs *BusinessStore) unmarkOpen(ctx context.Context, employee string, cas gocb.Cas) {
+	_, _ = bs.entryCol.Remove(openMarkKey(employee), &gocb.RemoveOptions{
+		Context: ctx,
+		Cas:     cas,
+	})
+}
+
+// openTimeEntry returns the entry employee is clocked in on, if any, and the
+// CAS to replace it with.
+func (bs *BusinessStore) openTimeEntry(ctx context.Context, employee string) (TimeEntry, gocb.Cas, bool, error) {
+	res, err := bs.entryCol.Get(openMarkKey(employee), &gocb.GetOptions{
+		Context: ctx,
+	})
+	if errors.Is(err, gocb.ErrDocumentNotFound) {
+		return TimeEntry{}, 0, false, nil
+	}
+	if err != nil {
+		return TimeEntry{}, 0, false, err
+	}
+	var mark openMark
+	err = res.Content(&mark)
+	if err != nil {
+		return TimeEntry{}, 0, false, err
+	}
+
+	te, cas, err := bs.getTimeEntryForUpdate(ctx, mark.Entry)
+	if errors.Is(err, ErrTimeEntryNotFound) || (err == nil && !te.Open()) {
+		bs.unmarkOpen(ctx, employee, res.Cas())
+		return TimeEntry{}, 0, false, nil
+	}
+	if err != nil {
+		return TimeEntry{}, 0, false, err
+	}
+	return te, cas, true, nil
+}
+
+// TimeEntries returns the entries of employee clocked in on between from and
+// to, formatted as 2006-01-02 and both inclusive, ordered by clock-in. An
+// empty employee matches everyone.
+func (bs *BusinessStore) TimeEntries(ctx context.Context, employee string, from string, to string) ([]TimeEntry, error) {
+	conditions := []string{"x.date >= $from", "x.date <= $to"}
+	params := map[string]interface{}{
+		"from": from,
+		"to":   to,
+	}
+	if employee != "" {
+		conditions = append(conditions, "x.employee = $employee")
+		params["employee"] = employee
+	}
+
+	res, err := bs.scope.Query(
+		"SELECT x.* FROM time_entries x WHERE "+strings.Join(conditions, " AND ")+" ORDER BY x.clockIn",
+		&gocb.QueryOptions{
+			Context:         ctx,
+			NamedParameters: params,
+			ScanConsistency: gocb.QueryScanConsistencyRequestPlus,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close() // ignore error
+
+	entries := []TimeEntry{}
+	for res.Next() {
+		var te TimeEntry
+		err := res.Row(&te)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, te)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+	}
+
+	return entries, res.Err()
+}
+
+// CorrectTimeEntry lets a manager replace the punches of an entry, e.g. when
+// an employee forgot to clock out. Entries of approved timesheets cannot be
+// corrected.
+func (bs *BusinessStore) CorrectTimeEntry(
+	ctx context.Context,
+	id string,
+	clockIn time.Time,
+	clockOut *time.Time,
+	breaks []Break,
+	actor string,
+) (TimeEntry, error) {
+	te, cas, err := bs.getTimeEntryForUpdate(ctx, id)
+	if err != nil {
+		return TimeEntry{}, err
+	}
+	err = bs.checkTimesheetOpen(ctx, te.Employee, te.Date)
+	if err != nil {
+		return TimeEntry{}, err
+	}
+	prev := te
+	reopened := !te.Open() && clockOut == nil
+
+	te.ClockIn = clockIn.In(bs.Location())
+	te.ClockOut = nil
+	if clockOut != nil {
+		out := clockOut.In(bs.Location())
+		te.ClockOut = &out
+	}
+	te.Breaks = breaks
+	if te.Breaks == nil {
+		te.Breaks = []Break{}
+	}
+	err = te.validate()
+	if err != nil {
+		return TimeEntry{}, err
+	}
+
+	if date := te.ClockIn.Format(time.DateOnly); date != te.Date {
+		te.Date = date
+		err = bs.checkTimesheetOpen(ctx, te.Employee, te.Date)
+		if err != nil {
+			return TimeEntry{}, err
+		}
+		te.Shift, err = bs.matchShift(ctx, te.Employee, te.ClockIn)
+		if err != nil {
+			return TimeEntry{}, err
+		}
+	}
+
+	if reopened {
+		_, _, open, err := bs.openTimeEntry(ctx, te.Employee)
+		if err != nil {
+			return TimeEntry{}, err
+		}
+		if open {
+			return TimeEntry{}, ErrAlreadyClockedIn
+		}
+	}
+
+	now := time.Now().In(bs.Location())
+	te.EditedBy = actor
+	te.EditedAt = &now
+	cas, err = bs.replaceTimeEntry(ctx, te, cas)
+	if err != nil {
+		return TimeEntry{}, err
+	}
+
+	switch {
+	case reopened:
+		// the entry is written before it is marked open, so that the mark
+		// never points to a closed entry
+		err = bs.markOpen(ctx, te)
+		if err != nil {
+			_, revertErr := bs.replaceTimeEntry(ctx, prev, cas)
+			return TimeEntry{}, errors.Join(err, revertErr)
+		}
+	case prev.Open() && !te.Open():
+		bs.unmarkOpen(ctx, te.Employee, 0)
+	}
+	return te, nil
+}
@@ -0,0 +1,390 @@
+package business
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+const (
+	TimesheetOpen     = "open"
+	TimesheetApproved = "approved"
+	TimesheetRejected = "rejected"
+)
+
+const (
+	// VarianceWorked is a scheduled shift the employee clocked in on.
+	VarianceWorked = "worked"
+	// VarianceMissed is a scheduled shift that has ended without the
+	// employee clocking in on it.
+	VarianceMissed = "missed"
+	// VarianceUpcoming is a scheduled shift that has not ended yet and has no
+	// time entries.
+	VarianceUpcoming = "upcoming"
+	// VarianceUnscheduled is work matching no scheduled shift.
+	VarianceUnscheduled = "unscheduled"
+)
+
+var (
+	ErrTimesheetApproved = errors.New("timesheet has been approved")
+	ErrOpenTimeEntries   = errors.New("timesheet has time entries without a clock-out")
+	ErrTimesheetChanged  = errors.New("timesheet was changed at the same time, try again")
+)
+
+// ShiftVariance compares a scheduled shift with the work done for it.
+// Unscheduled work has no shift and is listed once per time entry.
+type ShiftVariance struct {
+	Status           string    `json:"status"`
+	Date             string    `json:"date"`
+	Shift            *ShiftRef `json:"shift,omitempty"`
+	ScheduledMinutes int       `json:"scheduledMinutes"`
+	WorkedMinutes    int       `json:"workedMinutes"`
+	// StartMinutes is how much later than scheduled the employee clocked
+	// in, negative if earlier. EndMinutes is the same for clocking out.
+	StartMinutes int `json:"startMinutes"`
+	EndMinutes   int `json:"endMinutes"`
+	// VarianceMinutes is WorkedMinutes less ScheduledMinutes, zero for
+	// shifts that are upcoming or still being worked.
+	VarianceMinutes int      `json:"varianceMinutes"`
+	Entries         []string `json:"entries"`
+}
+
+type TimesheetDay struct {
+	Date             string `json:"date"`
+	ScheduledMinutes int    `json:"scheduledMinutes"`
+	WorkedMinutes    int    `json:"workedMinutes"`
+	BreakMinutes     int    `json:"breakMinutes"`
+	VarianceMinutes  int    `json:"varianceMinutes"`
+}
+
+// TimesheetApproval is the decision of a manager on the timesheet of an
+// employee for a period. Time entries of approved timesheets are final.
+type TimesheetApproval struct {
+	Employee  string    `json:"employee"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Status    string    `json:"status"`
+	Note      string    `json:"note,omitempty"`
+	DecidedBy string    `json:"decidedBy"`
+	DecidedAt time.Time `json:"decidedAt"`
+	// WorkedMinutes is what the timesheet added up to when it was decided.
+	WorkedMinutes int `json:"workedMinutes"`
+}
+
+// Timesheet is the work of an employee from From up to and including To,
+// compared with the schedule. Work counts on the day of the clock-in and
+// shifts on the day they start.
+type Timesheet struct {
+	Employee         string          `json:"employee"`
+	From             string          `json:"from"`
+	To               string          `json:"to"`
+	Status           string          `json:"status"`
+	Note             string          `json:"note,omitempty"`
+	DecidedBy        string          `json:"decidedBy,omitempty"`
+	DecidedAt        *time.Time      `json:"decidedAt,omitempty"`
+	ScheduledMinutes int             `json:"scheduledMinutes"`
+	WorkedMinutes    int             `json:"workedMinutes"`
+	BreakMinutes     int             `json:"breakMinutes"`
+	VarianceMinutes  int             `json:"varianceMinutes"`
+	Days             []TimesheetDay  `json:"days"`
+	Shifts           []ShiftVariance `json:"shifts"`
+	Entries          []TimeEntry     `json:"entries"`
+}
+
+func timesheetKey(employee string, from string, to string) string {
+	return employee + "::" + from + "::" + to
+}
+
+// Timesheet generates the timesheet of employee for the days from from up to
+// and including to.
+func (bs *BusinessStore) Timesheet(ctx context.Context, employee string, from time.Time, to time.Time) (Timesheet, error) {
+	ts, _, _, err := bs.timesheet(ctx, employee, from, to)
+	return ts, err
+}
+
+// timesheet also returns the decision stored for the timesheet and the CAS to
+// replace it with, which is zero if the timesheet was not decided yet.
+func (bs *BusinessStore) timesheet(ctx context.Context, employee string, from time.Time, to time.Time) (Timesheet, TimesheetApproval, gocb.Cas, error) {
+	fromStr, toStr := from.Format(time.DateOnly), to.Format(time.DateOnly)
+	var ta TimesheetApproval
+	var cas gocb.Cas
+	res, err := bs.sheetCol.Get(timesheetKey(employee, fromStr, toStr), &gocb.GetOptions{
+		Context: ctx,
+	})
+	if err != nil && !errors.Is(err, gocb.ErrDocumentNotFound) {
+		return Timesheet{}, TimesheetApproval{}, 0, err
+	}
+	if err == nil {
+		err = res.Content(&ta)
+		if err != nil {
+			return Timesheet{}, TimesheetApproval{}, 0, err
+		}
+		cas = res.Cas()
+	}
+
+	// overnight shifts reach into the day after the period and start on the
+	// day before it
+	entries, err := bs.TimeEntries(ctx, employee, fromStr, to.AddDate(0, 0, 1).Format(time.DateOnly))
+	if err != nil {
+		return Timesheet{}, TimesheetApproval{}, 0, err
+	}
+	planned, err := bs.plannedShifts(ctx, employee, from.AddDate(0, 0, -1), to)
+	if err != nil {
+		return Timesheet{}, TimesheetApproval{}, 0, err
+	}
+
+	ts := compileTimesheet(employee, from, to, planned, entries, time.Now())
+	if cas != 0 {
+		ts.Status = ta.Status
+		ts.Note = ta.Note
+		ts.DecidedBy = ta.DecidedBy
+		ts.DecidedAt = &ta.DecidedAt
+	}
+	return ts, ta, cas, nil
+}
+
+// compileTimesheet matches time entries with the planned shifts they were
+// for and adds them up per shift and day.
+func compileTimesheet(employee string, from time.Time, to time.Time, planned []plannedShift, entries []TimeEntry, now time.Time) Timesheet {
+	ts := Timesheet{
+		Employee: employee,
+		From:     from.Format(time.DateOnly),
+		To:       to.Format(time.DateOnly),
+		Status:   TimesheetOpen,
+		Days:     []TimesheetDay{},
+		Shifts:   []ShiftVariance{},
+		Entries:  []TimeEntry{},
+	}
+	for _, te := range entries {
+		if te.Date >= ts.From && te.Date <= ts.To {
+			ts.Entries = append(ts.Entries, te)
+		}
+	}
+
+	days := make(map[string]*TimesheetDay)
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		ts.Days = append(ts.Days, TimesheetDay{Date: date.Format(time.DateOnly)})
+	}
+	for i := range ts.Days {
+		days[ts.Days[i].Date] = &ts.Days[i]
+	}
+
+	byShift := make(map[ShiftRef][]TimeEntry)
+	for _, te := range entries {
+		if te.Shift != nil {
+			byShift[*te.Shift] = append(byShift[*te.Shift], te)
+		}
+		if day, ok := days[te.Date]; ok {
+			day.WorkedMinutes += int(te.Worked().Minutes())
+			day.BreakMinutes += int(te.BreakTime().Minutes())
+		}
+	}
+
+	for _, ps := range planned {
+		ref := ps.ref
+		if ref.Date < ts.From {
+			// counted in the timesheet of the day the shift starts on
+			delete(byShift, ref)
+			continue
+		}
+		sv := ShiftVariance{
+			Status:           VarianceWorked,
+			Date:             ref.Date,
+			Shift:            &ref,
+			ScheduledMinutes: int(ps.end.Sub(ps.start).Minutes()),
+			Entries:          []string{},
+		}
+		worked := byShift[ref]
+		delete(byShift, ref)
+		switch {
+		case len(worked) > 0:
+			first, last := worked[0], worked[len(worked)-1]
+			sv.StartMinutes = int(first.ClockIn.Sub(ps.start).Minutes())
+			if last.ClockOut != nil {
+				sv.EndMinutes = int(last.ClockOut.Sub(ps.end).Minutes())
+			}
+			for _, te := range worked {
+				sv.WorkedMinutes += int(te.Worked().Minutes())
+				sv.Entries = append(sv.Entries, te.ID)
+			}
+			// a shift that is still being worked has no variance yet
+			if last.ClockOut != nil {
+				sv.VarianceMinutes = sv.WorkedMinutes - sv.ScheduledMinutes
+			}
+		case ps.end.After(now):
+			sv.Status = VarianceUpcoming
+		default:
+			sv.Status = VarianceMissed
+			sv.VarianceMinutes = -sv.ScheduledMinutes
+		}
+		ts.Shifts = append(ts.Shifts, sv)
+	}
+
+	// entries for shifts that are no longer scheduled count as unscheduled
+	for _, te := range ts.Entries {
+		if te.Shift != nil {
+			if _, ok := byShift[*te.Shift]; !ok {
+				continue
+			}
+		}
+		worked := int(te.Worked().Minutes())
+		ts.Shifts = append(ts.Shifts, ShiftVariance{
+			Status:          VarianceUnscheduled,
+			Date:            te.Date,
+			WorkedMinutes:   worked,
+			VarianceMinutes: worked,
+			Entries:         []string{te.ID},
+		})
+	}
+	slices.SortStableFunc(ts.Shifts, func(a, b ShiftVariance) int {
+		return strings.Compare(a.Date, b.Date)
+	})
+
+	for _, sv := range ts.Shifts {
+		if day, ok := days[sv.Date]; ok {
+			day.ScheduledMinutes += sv.ScheduledMinutes
+			day.VarianceMinutes += sv.VarianceMinutes
+		}
+	}
+	for _, day := range ts.Days {
+		ts.ScheduledMinutes += day.ScheduledMinutes
+		ts.WorkedMinutes += day.WorkedMinutes
+		ts.BreakMinutes += day.BreakMinutes
+		ts.VarianceMinutes += day.VarianceMinutes
+	}
+	return ts
+}
+
+// DecideTimesheet approves or rejects the timesheet of employee for a period.
+// Approved timesheets cannot be decided again.
+func (bs *BusinessStore) DecideTimesheet(
+	ctx context.Context,
+	employee string,
+	from time.Time,
+	to time.Time,
+	approve bool,
+	note string,
+	actor string,
+) (Timesheet, error) {
+	ts, prev, cas, err := bs.timesheet(ctx, employee, from, to)
+	if err != nil {
+		return Timesheet{}, err
+	}
+	if ts.Status == TimesheetApproved {
+		return Timesheet{}, ErrTimesheetApproved
+	}
+	if approve && slices.ContainsFunc(ts.Entries, TimeEntry.Open) {
+		return Timesheet{}, ErrOpenTimeEntries
+	}
+
+	ta := TimesheetApproval{
+		Employee:      employee,
+		From:          ts.From,
+		To:            ts.To,
+		Status:        TimesheetRejected,
+		Note:          note,
+		DecidedBy:     actor,
+		DecidedAt:     time.Now().In(bs.Location()),
+		WorkedMinutes: ts.WorkedMinutes,
+	}
+	if approve {
+		ta.Status = TimesheetApproved
+	}
+	key := timesheetKey(employee, ta.From, ta.To)
+	var res *gocb.MutationResult
+	if cas == 0 {
+		res, err = bs.sheetCol.Insert(key, ta, &gocb.InsertOptions{
+			Context: ctx,
+		})
+	} else {
+		res, err = bs.sheetCol.Replace(key, ta, &gocb.ReplaceOptions{
+			Context: ctx,
+			Cas:     cas,
+		})
+	}
+	if errors.Is(err, gocb.ErrDocumentExists) || errors.Is(err, gocb.ErrCasMismatch) {
+		return Timesheet{}, ErrTimesheetChanged
+	}
+	if err != nil {
+		return Timesheet{}, err
+	}
+
+	if approve {
+		// punches and corrections check for approval before they write, so
+		// one that landed since the entries were read undoes the approval
+		entries, err := bs.TimeEntries(ctx, employee, ts.From, ts.To)
+		if err != nil {
+			return Timesheet{}, err
+		}
+		if !sameWork(ts.Entries, entries) {
+			if cas == 0 {
+				_, err = bs.sheetCol.Remove(key, &gocb.RemoveOptions{
+					Context: ctx,
+					Cas:     res.Cas(),
+				})
+			} else {
+				_, err = bs.sheetCol.Replace(key, prev, &gocb.ReplaceOptions{
+					Context: ctx,
+					Cas:     res.Cas(),
+				})
+			}
+			return Timesheet{}, errors.Join(ErrTimesheetChanged, err)
+		}
+	}
+
+	ts.Status = ta.Status
+	ts.Note = ta.Note
+	ts.DecidedBy = ta.DecidedBy
+	ts.DecidedAt = &ta.DecidedAt
+	return ts, nil
+}
+
+// sameWork reports whether entries b add up to the same finished work as a.
+func sameWork(a []TimeEntry, b []TimeEntry) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].ID != b[i].ID || a[i].Open() != b[i].Open() || a[i].Worked() != b[i].Worked() {
+			return false
+		}
+	}
+	return true
+}
+
+// checkTimesheetOpen returns ErrTimesheetApproved if date is part of an
+// approved timesheet of employee.
+func (bs *BusinessStore) checkTimesheetOpen(ctx context.Context, employee string, date string) error {
+	res, err := bs.scope.Query(
+		"SELECT RAW COUNT(*) FROM timesheets x WHERE x.employee = $employee AND x.status = $status AND x.`from` <= $date AND x.`to` >= $date",
+		&gocb.QueryOptions{
+			Context: ctx,
+			NamedParameters: map[string]interface{}{
+				"employee": employee,
+				"status":   TimesheetApproved,
+				"date":     date,
+			},
+			// a timesheet approved just before must block changes to its
+			// entries
+			ScanConsistency: gocb.QueryScanConsistencyRequestPlus,
+		},
+	)
+	if err != nil {
+		return err
+	}
+	defer res.Close() // ignore error
+
+	var count int
+	err = res.One(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrTimesheetApproved
+	}
+	return nil
+}
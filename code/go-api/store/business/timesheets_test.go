@@ -0,0 +1,127 @@
+package business
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCompileTimesheet(t *testing.T) {
+	at := func(dayIdx int, s string) time.Time {
+		return testWeek.AddDate(0, 0, dayIdx).Add(time.Duration(clock(s)) * time.Minute)
+	}
+	planned := func(dayIdx int, from string, to string) plannedShift {
+		ws := work(dayIdx, from, to)
+		return plannedShift{
+			ref:   ShiftRef{Date: ws.start.Format(time.DateOnly), From: clock(from), To: clock(to)},
+			start: ws.start,
+			end:   ws.end,
+		}
+	}
+	entry := func(id string, dayIdx int, shift *plannedShift, clockIn string, clockOut string, breaks ...Break) TimeEntry {
+		te := TimeEntry{
+			ID:       id,
+			Employee: "a@x.se",
+			Date:     testWeek.AddDate(0, 0, dayIdx).Format(time.DateOnly),
+			ClockIn:  at(dayIdx, clockIn),
+			Breaks:   breaks,
+		}
+		if shift != nil {
+			te.Shift = &shift.ref
+		}
+		if clockOut != "" {
+			out := at(dayIdx, clockOut)
+			te.ClockOut = &out
+		}
+		return te
+	}
+	breakTime := func(dayIdx int, from string, to string) Break {
+		end := at(dayIdx, to)
+		return Break{Start: at(dayIdx, from), End: &end}
+	}
+
+	monday := planned(0, "09:00", "17:00")
+	tuesday := planned(1, "09:00", "17:00")
+	wednesday := planned(2, "09:00", "17:00")
+	removed := planned(2, "06:00", "08:00")
+	sundayNight := planned(-1, "22:00", "06:00")
+	wednesdayNight := planned(2, "22:00", "06:00")
+
+	type variance struct {
+		status   string
+		date     string
+		worked   int
+		variance int
+	}
+	tests := []struct {
+		name         string
+		planned      []plannedShift
+		entries      []TimeEntry
+		now          time.Time
+		want         []variance
+		wantWorked   int
+		wantBreak    int
+		wantVariance int
+	}{
+		{
+			name:    "worked, missed, upcoming and unscheduled",
+			planned: []plannedShift{monday, tuesday, wednesday},
+			entries: []TimeEntry{
+				entry("1", 0, &monday, "09:10", "17:05", breakTime(0, "12:00", "12:30")),
+				entry("2", 1, nil, "18:00", "20:00"),
+				entry("3", 2, &removed, "06:00", "07:00"),
+			},
+			now: at(2, "10:00"),
+			want: []variance{
+				{VarianceWorked, "2026-10-12", 445, -35},
+				{VarianceMissed, "2026-10-13", 0, -480},
+				{VarianceUnscheduled, "2026-10-13", 120, 120},
+				{VarianceUpcoming, "2026-10-14", 0, 0},
+				{VarianceUnscheduled, "2026-10-14", 60, 60},
+			},
+			wantWorked:   625,
+			wantBreak:    30,
+			wantVariance: -335,
+		},
+		{
+			name:    "overnight shifts across the ends of the period",
+			planned: []plannedShift{sundayNight, wednesdayNight},
+			entries: []TimeEntry{
+				entry("1", 0, &sundayNight, "00:00", "06:00"),
+				entry("2", 3, &wednesdayNight, "00:15", "06:00"),
+			},
+			now:          at(4, "10:00"),
+			want:         []variance{{VarianceWorked, "2026-10-14", 345, -135}},
+			wantWorked:   360,
+			wantVariance: -135,
+		},
+		{
+			name:    "shift still being worked",
+			planned: []plannedShift{monday},
+			entries: []TimeEntry{entry("1", 0, &monday, "09:00", "")},
+			now:     at(0, "12:00"),
+			want:    []variance{{VarianceWorked, "2026-10-12", 0, 0}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := compileTimesheet("a@x.se", testWeek, testWeek.AddDate(0, 0, 2), tc.planned, tc.entries, tc.now)
+
+			var got []variance
+			for _, sv := range ts.Shifts {
+				got = append(got, variance{sv.Status, sv.Date, sv.WorkedMinutes, sv.VarianceMinutes})
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("shifts: got %v, want %v", got, tc.want)
+			}
+			if len(ts.Days) != 3 {
+				t.Fatalf("got %d days, want 3", len(ts.Days))
+			}
+			if ts.WorkedMinutes != tc.wantWorked || ts.BreakMinutes != tc.wantBreak || ts.VarianceMinutes != tc.wantVariance {
+				t.Errorf("got worked %d, break %d, variance %d, want worked %d, break %d, variance %d",
+					ts.WorkedMinutes, ts.BreakMinutes, ts.VarianceMinutes, tc.wantWorked, tc.wantBreak, tc.wantVariance)
+			}
+		})
+	}
+}
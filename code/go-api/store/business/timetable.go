@@ -1,6 +1,7 @@
 package business
 
 import (
+	"airdock/store"
 	"context"
 	"errors"
 	"fmt"
@@ -19,11 +20,20 @@ type BusinessStore struct {
 	scope       *gocb.Scope
 	configCol   *gocb.Collection
 	scheduleCol *gocb.Collection
-
+	overrideCol *gocb.Collection
+	templateCol *gocb.Collection
+	historyCol  *gocb.Collection
+	swapCol     *gocb.Collection
+	claimCol    *gocb.Collection
+	entryCol    *gocb.Collection
+	sheetCol    *gocb.Collection
+	statusCol   *gocb.Collection
+
+	tz     *store.TimeZone
 	logger *log.Logger
 }
 
-func NewBusinessStore(bucket *gocb.Bucket, logger *log.Logger) BusinessStore {
+func NewBusinessStore(bucket *gocb.Bucket, tz *store.TimeZone, logger *log.Logger) BusinessStore {
 	err := bucket.CollectionsV2().CreateScope("business", &gocb.CreateScopeOptions{})
 	if err != nil && !errors.Is(err, gocb.ErrScopeExists) {
 		logger.Fatal("failed to create scope", "err", err)
@@ -41,13 +51,69 @@ func NewBusinessStore(bucket *gocb.Bucket, logger *log.Logger) BusinessStore {
 		logger.Fatal("failed to create collection", "err", err)
 	}
 
-	return BusinessStore{
+	err = bucket.CollectionsV2().CreateCollection(scope.Name(), "overrides", &gocb.CreateCollectionSettings{}, &gocb.CreateCollectionOptions{})
+	if err != nil && !errors.Is(err, gocb.ErrCollectionExists) {
+		logger.Fatal("failed to create collection", "err", err)
+	}
+
+	err = bucket.CollectionsV2().CreateCollection(scope.Name(), "templates", &gocb.CreateCollectionSettings{}, &gocb.CreateCollectionOptions{})
+	if err != nil && !errors.Is(err, gocb.ErrCollectionExists) {
+		logger.Fatal("failed to create collection", "err", err)
+	}
+
+	err = bucket.CollectionsV2().CreateCollection(scope.Name(), "schedule_history", &gocb.CreateCollectionSettings{}, &gocb.CreateCollectionOptions{})
+	if err != nil && !errors.Is(err, gocb.ErrCollectionExists) {
+		logger.Fatal("failed to create collection", "err", err)
+	}
+
+	err = bucket.CollectionsV2().CreateCollection(scope.Name(), "swaps", &gocb.CreateCollectionSettings{}, &gocb.CreateCollectionOptions{})
+	if err != nil && !errors.Is(err, gocb.ErrCollectionExists) {
+		logger.Fatal("failed to create collection", "err", err)
+	}
+
+	err = bucket.CollectionsV2().CreateCollection(scope.Name(), "claims", &gocb.CreateCollectionSettings{}, &gocb.CreateCollectionOptions{})
+	if err != nil && !errors.Is(err, gocb.ErrCollectionExists) {
+		logger.Fatal("failed to create collection", "err", err)
+	}
+
+	err = bucket.CollectionsV2().CreateCollection(scope.Name(), "time_entries", &gocb.CreateCollectionSettings{}, &gocb.CreateCollectionOptions{})
+	if err != nil && !errors.Is(err, gocb.ErrCollectionExists) {
+		logger.Fatal("failed to create collection", "err", err)
+	}
+
+	err = bucket.CollectionsV2().CreateCollection(scope.Name(), "timesheets", &gocb.CreateCollectionSettings{}, &gocb.CreateCollectionOptions{})
+	if err != nil && !errors.Is(err, gocb.ErrCollectionExists) {
+		logger.Fatal("failed to create collection", "err", err)
+	}
+
+	err = bucket.CollectionsV2().CreateCollection(scope.Name(), "schedule_status", &gocb.CreateCollectionSettings{}, &gocb.CreateCollectionOptions{})
+	if err != nil && !errors.Is(err, gocb.ErrCollectionExists) {
+		logger.Fatal("failed to create collection", "err", err)
+	}
+
+	bs := BusinessStore{
 		bucket:      bucket,
 		scope:       scope,
 		configCol:   scope.Collection("configs"),
 		scheduleCol: scope.Collection("schedule"),
+		overrideCol: scope.Collection("overrides"),
+		templateCol: scope.Collection("templates"),
+		historyCol:  scope.Collection("schedule_history"),
+		swapCol:     scope.Collection("swaps"),
+		claimCol:    scope.Collection("claims"),
+		entryCol:    scope.Collection("time_entries"),
+		sheetCol:    scope.Collection("timesheets"),
+		statusCol:   scope.Collection("schedule_status"),
+		tz:          tz,
 		logger:      logger,
 	}
+
+	err = bs.loadTimeZone(context.Background())
+	if err != nil {
+		logger.Fatal("failed to load business time zone", "err", err)
+	}
+
+	return bs
 }
 
 func (bs *BusinessStore) SetConfig(ctx context.Context, key string, value interface{}) error {
@@ -62,13 +128,62 @@ var (
 )
 
 type ShiftTimetable struct {
-	From              time.Time `json:"from"`
-	To                time.Time `json:"to"`
-	RequiredEmployees int       `json:"requiredEmployees"`
+	From              store.Clock `json:"from"`
+	To                store.Clock `json:"to"`
+	RequiredEmployees int         `json:"requiredEmployees"`
+	// Roles is how many of the RequiredEmployees must work a given role.
+	// The remaining employees may have any role.
+	Roles map[string]int `json:"roles,omitempty"`
+}
+
+// Overnight reports whether the shift ends on the day after it starts, which
+// is the case when To is not after From.
+func (s ShiftTimetable) Overnight() bool {
+	return isOvernight(s.From, s.To)
 }
+
+func (s ShiftTimetable) Duration() time.Duration {
+	return shiftDuration(s.From, s.To)
+}
+
+// RoleNames returns the roles required by the shift in sorted order.
+func (s ShiftTimetable) RoleNames() []string {
+	return sortedKeys(s.Roles)
+}
+
 type DayTimetable struct {
 	Shifts []ShiftTimetable `json:"shifts"`
+	// ContinuedShifts are the overnight shifts of the previous day that
+	// continue into this day. Only filled in for daily views, never stored.
+	ContinuedShifts []ShiftTimetable `json:"continuedShifts,omitempty"`
+	// Holiday is the holiday falling on the day, if any. Only filled in for
+	// resolved timetables, never stored.
+	Holiday *Holiday `json:"holiday,omitempty"`
 }
+
+func (dtt DayTimetable) overnightShifts() []ShiftTimetable {
+	var shifts []ShiftTimetable
+	for _, s := range dtt.Shifts {
+		if s.Overnight() {
+			shifts = append(shifts, s)
+		}
+	}
+	return shifts
+}
+
+// withContinuedShifts fills in the ContinuedShifts of every day of tt, taking
+// those of Monday from prevSunday.
+func withContinuedShifts(tt WeekTimetable, prevSunday DayTimetable) WeekTimetable {
+	prev := prevSunday
+	for _, weekday := range Weekdays {
+		dtt := tt.Day(weekday)
+		dtt.ContinuedShifts = prev.overnightShifts()
+		prev = tt.Day(weekday)
+		tt.setDay(weekday, dtt)
+	}
+	return tt
+}
+
 type WeekTimetable struct {
 	Monday    DayTimetable `json:"monday"`
 	Tuesday   DayTimetable `json:"tuesday"`
@@ -79,6 +194,56 @@ type WeekTimetable struct {
 	Sunday    DayTimetable `json:"sunday"`
 }
 
+// Weekdays lists the days of a week in the order they appear in a
+// WeekTimetable and WeekSchedule, i.e. starting on Monday.
+var Weekdays = []time.Weekday{
+	time.Monday,
+	time.Tuesday,
+	time.Wednesday,
+	time.Thursday,
+	time.Friday,
+	time.Saturday,
+	time.Sunday,
+}
+
+func (tt WeekTimetable) Day(weekday time.Weekday) DayTimetable {
+	switch weekday {
+	case time.Monday:
+		return tt.Monday
+	case time.Tuesday:
+		return tt.Tuesday
+	case time.Wednesday:
+		return tt.Wednesday
+	case time.Thursday:
+		return tt.Thursday
+	case time.Friday:
+		return tt.Friday
+	case time.Saturday:
+		return tt.Saturday
+	default:
+		return tt.Sunday
+	}
+}
+
+func (tt *WeekTimetable) setDay(weekday time.Weekday, dtt DayTimetable) {
+	switch weekday {
+	case time.Monday:
+		tt.Monday = dtt
+	case time.Tuesday:
+		tt.Tuesday = dtt
+	case time.Wednesday:
+		tt.Wednesday = dtt
+	case time.Thursday:
+		tt.Thursday = dtt
+	case time.Friday:
+		tt.Friday = dtt
+	case time.Saturday:
+		tt.Saturday = dtt
+	default:
+		tt.Sunday = dtt
+	}
+}
+
 func (bs *BusinessStore) SetDefaultTimetable(ctx context.Context, tt WeekTimetable) error {
 	_, err := bs.configCol.Upsert(DefaultTimetableKey, tt, &gocb.UpsertOptions{
 		Context: ctx,
@@ -102,8 +267,23 @@ func (bs *BusinessStore) GetDefaultTimetable(ctx context.Context) (WeekTimetable
 	return tt, err
 }
 
+// timetableForWeek returns the timetable that applies to the week containing
+// the given date, with its overrides merged in.
+func (bs *BusinessStore) timetableForWeek(ctx context.Context, week time.Time) (WeekTimetable, error) {
+	weekStart := store.StartOfWeek(week)
+	r, err := bs.timetableResolver(ctx, weekStart, weekStart.AddDate(0, 0, 6))
+	if err != nil {
+		return WeekTimetable{}, err
+	}
+	return r.week(weekStart), nil
+}
+
 type DetailedWeekTimetable struct {
 	WeekStr string `json:"weekStr"`
+	// Template is the name of the template the week is based on and
+	// CycleWeek, counting from 1, the week of its cycle, if it rotates.
+	Template  string `json:"template,omitempty"`
+	CycleWeek int    `json:"cycleWeek,omitempty"`
 	WeekTimetable
 }
 
@@ -112,52 +292,36 @@ type WeeksTimetable struct {
 	Weeks         map[string]DetailedWeekTimetable `json:"weeks"`
 }
 
+// GetTimetable returns the effective timetable of every week from the week
+// containing from up to and including the week containing to, keyed by the
+// date of their Monday. Overrides are merged over the default timetable.
 func (bs *BusinessStore) GetTimetable(ctx context.Context, from time.Time, to time.Time) (WeeksTimetable, error) {
-	defaultTt, err := bs.GetDefaultTimetable(ctx)
+	lastWeek := store.StartOfWeek(to)
+	r, err := bs.timetableResolver(ctx, from, lastWeek.AddDate(0, 0, 6))
 	if err != nil {
 		return WeeksTimetable{}, err
 	}
 
-	startYear, startWeek := from.ISOWeek()
-	endYear, endWeek := to.ISOWeek()
-
-	if startYear == endYear {
-		weeks := make(map[string]DetailedWeekTimetable, endWeek-startWeek+1)
-		for i := startWeek; i <= endWeek; i++ {
-			week := from.AddDate(0, 0, 7*(i-startWeek))
-			weekDateStr := week.Format("2006-01-02")
-			_, weekNr := week.ISOWeek()
-			humanReadWeekStr := fmt.Sprintf("Week %d", weekNr)
-			weeks[weekDateStr] = DetailedWeekTimetable{
-				WeekStr:       humanReadWeekStr,
-				WeekTimetable: defaultTt,
-			}
-		}
-
-		return WeeksTimetable{
-			FirstWeekDate: from.Format("2006-01-02"),
-			Weeks:         weeks,
-		}, nil
-	}
-
-	weeks := make(map[string]DetailedWeekTimetable, endWeek-startWeek+1)
-	yearDiff := endYear - startYear
-	absEndWeek := endWeek + yearDiff*52
-	for i := startWeek; i <= absEndWeek; i++ {
-		adjustedWeekNr := i % 52
-		addYears := i / 52
-		week := from.AddDate(addYears, 0, 7*(adjustedWeekNr-startWeek))
-		weekDateStr := week.Format("2006-01-02")
+	weeks := make(map[string]DetailedWeekTimetable)
+	for week := store.StartOfWeek(from); !week.After(to); week = week.AddDate(0, 0, 7) {
 		_, weekNr := week.ISOWeek()
-		humanReadWeekStr := fmt.Sprintf("Week %d", weekNr)
-		weeks[weekDateStr] = DetailedWeekTimetable{
-			WeekStr:       humanReadWeekStr,
-			WeekTimetable: defaultTt,
+		dwt := DetailedWeekTimetable{
+			WeekStr:       fmt.Sprintf("Week %d", weekNr),
+			WeekTimetable: withContinuedShifts(r.week(week), r.day(week.AddDate(0, 0, -1))),
+		}
+		if t, ok := r.template(week); ok {
+			i, err := t.CycleWeek(week)
+			if err != nil {
+				return WeeksTimetable{}, err
+			}
+			dwt.Template = t.Name
+			dwt.CycleWeek = i + 1
 		}
+		weeks[week.Format("2006-01-02")] = dwt
 	}
 
 	return WeeksTimetable{
-		FirstWeekDate: from.Format("2006-01-02"),
+		FirstWeekDate: store.StartOfWeek(from).Format("2006-01-02"),
 		Weeks:         weeks,
 	}, nil
 }
@@ -167,26 +331,18 @@ type DaysTimetable struct {
 }
 
 func (bs *BusinessStore) Meep(ctx context.Context, from time.Time, to time.Time) (DaysTimetable, error) {
-	defaultTt, err := bs.GetDefaultTimetable(ctx)
+	r, err := bs.timetableResolver(ctx, from, to)
 	if err != nil {
 		return DaysTimetable{}, err
 	}
 
-	numDays := int(to.Sub(from).Hours() / 24)
-	bs.logger.Print("numDays", numDays)
-
-	days := make(map[string]DayTimetable, numDays)
-	for i := 0; i < numDays; i++ {
-		day := from.Add(time.Duration(i) * 24 * time.Hour)
-		dayStr := day.Format(time.DateOnly)
-		weekday := day.Weekday().String()
-
-		dtt, err := getDefaultDayTimetable(defaultTt, weekday)
-		if err != nil {
-			return DaysTimetable{}, err
-		}
-
-		days[dayStr] = dtt
+	// days are counted by date, not by 24 hours, as days with a daylight
+	// saving time change are shorter or longer
+	days := make(map[string]DayTimetable)
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		dtt := r.day(day)
+		dtt.ContinuedShifts = r.day(day.AddDate(0, 0, -1)).overnightShifts()
+		days[day.Format(time.DateOnly)] = dtt
 	}
 
 	return DaysTimetable{
@@ -194,31 +350,35 @@ func (bs *BusinessStore) Meep(ctx context.Context, from time.Time, to time.Time)
 	}, nil
 }
 
-func getDefaultDayTimetable(tt WeekTimetable, weekday string) (DayTimetable, error) {
-	switch weekday {
-	case "Monday":
-		return tt.Monday, nil
-	case "Tuesday":
-		return tt.Tuesday, nil
-	case "Wednesday":
-		return tt.Wednesday, nil
-	case "Thursday":
-		return tt.Thursday, nil
-	case "Friday":
-		return tt.Friday, nil
-	case "Saturday":
-		return tt.Saturday, nil
-	case "Sunday":
-		return tt.Sunday, nil
-	default:
-		return DayTimetable{}, errors.New("unknown weekday")
-	}
+type ShiftSchedule struct {
+	From      store.Clock `json:"from"`
+	To        store.Clock `json:"to"`
+	Employees []string    `json:"employees"`
+	// Roles maps a role to the employees working it. Everyone in Roles is
+	// also listed in Employees.
+	Roles map[string][]string `json:"roles,omitempty"`
 }
 
-type ShiftSchedule struct {
-	From      time.Time `json:"from"`
-	To        time.Time `json:"to"`
-	Employees []string  `json:"employees"`
+func (s ShiftSchedule) Overnight() bool {
+	return isOvernight(s.From, s.To)
+}
+
+func (s ShiftSchedule) Duration() time.Duration {
+	return shiftDuration(s.From, s.To)
+}
+
+func isOvernight(from store.Clock, to store.Clock) bool {
+	return to <= from
+}
+
+// shiftDuration is the nominal length of a shift. On days with a daylight
+// saving time change use the instants from shiftInterval instead.
+func shiftDuration(from store.Clock, to store.Clock) time.Duration {
+	minutes := int(to - from)
+	if isOvernight(from, to) {
+		minutes += 24 * 60
+	}
+	return time.Duration(minutes) * time.Minute
 }
 
 type DaySchedule struct {
@@ -235,19 +395,55 @@ type WeekSchedule struct {
 	Sunday    DaySchedule `json:"sunday"`
 }
 
-func (bs *BusinessStore) CreateScheduleForWeek(ctx context.Context, week time.Time, ws WeekSchedule) error {
-	weekStr := week.Format("2006-01-02")
-	_, err := bs.scheduleCol.Upsert(weekStr, ws, &gocb.UpsertOptions{
-		Context: ctx,
-	})
-	return err
+func (ws WeekSchedule) Day(weekday time.Weekday) DaySchedule {
+	switch weekday {
+	case time.Monday:
+		return ws.Monday
+	case time.Tuesday:
+		return ws.Tuesday
+	case time.Wednesday:
+		return ws.Wednesday
+	case time.Thursday:
+		return ws.Thursday
+	case time.Friday:
+		return ws.Friday
+	case time.Saturday:
+		return ws.Saturday
+	default:
+		return ws.Sunday
+	}
+}
+
+func (ws *WeekSchedule) SetDay(weekday time.Weekday, ds DaySchedule) {
+	switch weekday {
+	case time.Monday:
+		ws.Monday = ds
+	case time.Tuesday:
+		ws.Tuesday = ds
+	case time.Wednesday:
+		ws.Wednesday = ds
+	case time.Thursday:
+		ws.Thursday = ds
+	case time.Friday:
+		ws.Friday = ds
+	case time.Saturday:
+		ws.Saturday = ds
+	default:
+		ws.Sunday = ds
+	}
 }
 
 func (bs *BusinessStore) GetScheduleForWeek(ctx context.Context, week time.Time) (WeekSchedule, error) {
-	weekStr := week.Format("2006-01-02")
+	weekStr := store.StartOfWeek(week).Format("2006-01-02")
 	res, err := bs.scheduleCol.Get(weekStr, &gocb.GetOptions{
 		Context: ctx,
 	})
+	if errors.Is(err, gocb.ErrDocumentNotFound) && weekStr != week.Format("2006-01-02") {
+		// schedules used to be keyed by whatever date the client sent
+		res, err = bs.scheduleCol.Get(week.Format("2006-01-02"), &gocb.GetOptions{
+			Context: ctx,
+		})
+	}
 	if errors.Is(err, gocb.ErrDocumentNotFound) {
 		return WeekSchedule{}, ErrConfigNotFound
 	}
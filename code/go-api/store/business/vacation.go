@@ -0,0 +1,266 @@
+package business
+
+import (
+	"airdock/store"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+const (
+	VacationPolicyKey = "vacation-policy"
+)
+
+const (
+	// AccrualYearly grants the whole entitlement of a year on January 1.
+	AccrualYearly = "yearly"
+	// AccrualMonthly grants a twelfth of the entitlement at the end of every
+	// month.
+	AccrualMonthly = "monthly"
+)
+
+// VacationPolicy decides how many vacation days employees earn and how many
+// of them they may keep at the end of a year.
+type VacationPolicy struct {
+	// AnnualDays is the entitlement of a full year.
+	AnnualDays float64 `json:"annualDays"`
+	Accrual    string  `json:"accrual"`
+	// Prorate reduces the yearly entitlement in the year an employee was
+	// hired by the part of the year before the hire date. Monthly accrual
+	// always starts with the month of the hire date.
+	Prorate bool `json:"prorate"`
+	// MaxCarryOver is how many unused days are carried over into the next
+	// year; the rest is forfeited. Overdrawn days are always carried over.
+	MaxCarryOver float64 `json:"maxCarryOver"`
+	// WorkdayHours is the length of a working day, which partial days of
+	// leave are counted against.
+	WorkdayHours float64 `json:"workdayHours"`
+}
+
+func defaultVacationPolicy() VacationPolicy {
+	return VacationPolicy{
+		AnnualDays:   25,
+		Accrual:      AccrualYearly,
+		Prorate:      true,
+		MaxCarryOver: 5,
+		WorkdayHours: 8,
+	}
+}
+
+func (bs *BusinessStore) GetVacationPolicy(ctx context.Context) (VacationPolicy, error) {
+	res, err := bs.configCol.Get(VacationPolicyKey, &gocb.GetOptions{
+		Context: ctx,
+	})
+	if errors.Is(err, gocb.ErrDocumentNotFound) {
+		return defaultVacationPolicy(), nil
+	}
+	if err != nil {
+		return VacationPolicy{}, err
+	}
+
+	var vp VacationPolicy
+	err = res.Content(&vp)
+	return vp, err
+}
+
+func (bs *BusinessStore) SetVacationPolicy(ctx context.Context, vp VacationPolicy) error {
+	if vp.Accrual != AccrualYearly && vp.Accrual != AccrualMonthly {
+		return fmt.Errorf("unknown accrual %q", vp.Accrual)
+	}
+	if vp.WorkdayHours <= 0 {
+		return errors.New("workday hours must be positive")
+	}
+	return bs.SetConfig(ctx, VacationPolicyKey, vp)
+}
+
+// VacationBalance is the vacation of an employee in a year, in days.
+// Remaining is what is left of CarriedOver and Entitled after Used; Pending
+// is requested but not approved yet.
+type VacationBalance struct {
+	Employee    string  `json:"employee"`
+	Year        int     `json:"year"`
+	CarriedOver float64 `json:"carriedOver"`
+	Entitled    float64 `json:"entitled"`
+	Used        float64 `json:"used"`
+	Pending     float64 `json:"pending"`
+	Remaining   float64 `json:"remaining"`
+}
+
+// VacationBalances returns the balance of every employee in year. leave must
+// hold all leave up to the end of year.
+func (bs *BusinessStore) VacationBalances(
+	ctx context.Context,
+	year int,
+	employees []store.Employee,
+	leave []store.LeaveRequest,
+) ([]VacationBalance, error) {
+	vp, err := bs.GetVacationPolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	loc := bs.Location()
+	firstYear := year
+	for _, e := range employees {
+		if hired, ok := hireDate(e, loc); ok && hired.Year() < firstYear {
+			firstYear = hired.Year()
+		}
+	}
+	holidays, err := bs.GetHolidays(ctx, time.Date(firstYear, time.January, 1, 0, 0, 0, 0, loc), time.Date(year, time.December, 31, 0, 0, 0, 0, loc))
+	if err != nil {
+		return nil, err
+	}
+	c := vacationCounter{
+		policy:   vp,
+		loc:      loc,
+		asOf:     time.Now().In(loc),
+		holidays: make(map[string]bool, len(holidays)),
+	}
+	for _, h := range holidays {
+		c.holidays[h.Date] = true
+	}
+
+	byEmployee := make(map[string][]store.LeaveRequest)
+	for _, lr := range leave {
+		if lr.Type == store.LeaveVacation {
+			byEmployee[lr.Employee] = append(byEmployee[lr.Employee], lr)
+		}
+	}
+
+	balances := make([]VacationBalance, 0, len(employees))
+	for _, e := range employees {
+		balances = append(balances, c.balance(e, year, byEmployee[e.Email]))
+	}
+	return balances, nil
+}
+
+func hireDate(e store.Employee, loc *time.Location) (time.Time, bool) {
+	if e.HireDate == 0 {
+		return time.Time{}, false
+	}
+	y, m, d := time.Unix(e.HireDate, 0).UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc), true
+}
+
+type vacationCounter struct {
+	policy   VacationPolicy
+	loc      *time.Location
+	asOf     time.Time
+	holidays map[string]bool
+}
+
+// balance carries the balance of e over from year to year, starting in the
+// year of hire.
+func (c vacationCounter) balance(e store.Employee, year int, leave []store.LeaveRequest) VacationBalance {
+	hired, ok := hireDate(e, c.loc)
+	first := year
+	if ok {
+		first = min(hired.Year(), year)
+	}
+
+	vb := VacationBalance{
+		Employee: e.Email,
+		Year:     year,
+	}
+	carried := 0.0
+	for y := first; y <= year; y++ {
+		vb.CarriedOver = carried
+		vb.Entitled = c.entitlement(y, hired, ok)
+		vb.Used, vb.Pending = 0, 0
+		for _, lr := range leave {
+			switch lr.Status {
+			case store.LeaveApproved:
+				vb.Used += c.days(lr, y)
+			case store.LeavePending:
+				vb.Pending += c.days(lr, y)
+			}
+		}
+		vb.Remaining = vb.CarriedOver + vb.Entitled - vb.Used
+
+		carried = vb.Remaining
+		if carried > c.policy.MaxCarryOver {
+			carried = c.policy.MaxCarryOver
+		}
+	}
+
+	vb.CarriedOver = roundDays(vb.CarriedOver)
+	vb.Entitled = roundDays(vb.Entitled)
+	vb.Used = roundDays(vb.Used)
+	vb.Pending = roundDays(vb.Pending)
+	vb.Remaining = roundDays(vb.Remaining)
+	return vb
+}
+
+// entitlement returns the days earned in year, before hire dates are taken
+// into account if known.
+func (c vacationCounter) entitlement(year int, hired time.Time, known bool) float64 {
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, c.loc)
+	end := time.Date(year, time.December, 31, 0, 0, 0, 0, c.loc)
+	if known && hired.After(end) {
+		return 0
+	}
+
+	if c.policy.Accrual == AccrualMonthly {
+		months := 0
+		for m := time.January; m <= time.December; m++ {
+			// the last day of the month
+			monthEnd := time.Date(year, m+1, 0, 0, 0, 0, 0, c.loc)
+			if known && monthEnd.Before(hired) {
+				continue
+			}
+			if monthEnd.After(c.asOf) {
+				break
+			}
+			months++
+		}
+		return c.policy.AnnualDays * float64(months) / 12
+	}
+
+	if !c.policy.Prorate || !known || !hired.After(start) {
+		return c.policy.AnnualDays
+	}
+	// count calendar days, which differ from 24 hours across daylight saving
+	// time changes
+	daysInYear := end.YearDay()
+	employed := daysInYear - hired.YearDay() + 1
+	return c.policy.AnnualDays * float64(employed) / float64(daysInYear)
+}
+
+// days returns the vacation days lr takes up in year. Partial days count
+// against the length of a working day.
+func (c vacationCounter) days(lr store.LeaveRequest, year int) float64 {
+	first, err := time.ParseInLocation(time.DateOnly, lr.From, c.loc)
+	if err != nil {
+		return 0
+	}
+	last, err := time.ParseInLocation(time.DateOnly, lr.To, c.loc)
+	if err != nil {
+		return 0
+	}
+
+	days := 0.0
+	for date := first; !date.After(last); date = date.AddDate(0, 0, 1) {
+		if date.Year() != year || date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
+			continue
+		}
+		if c.holidays[date.Format(time.DateOnly)] {
+			continue
+		}
+		if lr.Hours == nil {
+			days++
+			continue
+		}
+		hours := time.Duration(lr.Hours.To-lr.Hours.From) * time.Minute
+		days += math.Min(hours.Hours()/c.policy.WorkdayHours, 1)
+	}
+	return days
+}
+
+// roundDays rounds to hundredths of a day.
+func roundDays(days float64) float64 {
+	return math.Round(days*100) / 100
+}
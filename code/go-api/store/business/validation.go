@@ -0,0 +1,197 @@
+package business
+
+import (
+	"airdock/store"
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	ViolationUnknownEmployee   = "unknown_employee"
+	ViolationUnavailable       = "employee_unavailable"
+	ViolationUnknownAvailable  = "availability_unknown"
+	ViolationUnknownShift      = "unknown_shift"
+	ViolationOverstaffed       = "overstaffed"
+	ViolationDuplicateEmployee = "duplicate_employee"
+	ViolationOverlappingShifts = "overlapping_shifts"
+	ViolationUnknownRole       = "unknown_role"
+	ViolationRoleOverstaffed   = "role_overstaffed"
+	ViolationRoleNotInShift    = "role_not_in_shift"
+	ViolationMissingSkill      = "missing_skill"
+)
+
+type Violation struct {
+	Code     string     `json:"code"`
+	Date     string     `json:"date"`
+	Weekday  string     `json:"weekday,omitempty"`
+	From     *time.Time `json:"from,omitempty"`
+	To       *time.Time `json:"to,omitempty"`
+	Employee string     `json:"employee,omitempty"`
+	Message  string     `json:"message"`
+	// severity is how far a working-time rule is broken, comparable between
+	// violations of the same rule.
+	severity float64
+}
+
+type ValidationResult struct {
+	Valid      bool        `json:"valid"`
+	Violations []Violation `json:"violations"`
+}
+
+func (bs *BusinessStore) ValidateScheduleForWeek(
+	ctx context.Context,
+	week time.Time,
+	ws WeekSchedule,
+	employees []store.Employee,
+	ava map[string]store.WeekAvailability,
+) (ValidationResult, error) {
+	tt, err := bs.timetableForWeek(ctx, week)
+	if err != nil {
+		return ValidationResult{}, err
+	}
+
+	cc, err := bs.complianceContext(ctx, week)
+	if err != nil {
+		return ValidationResult{}, err
+	}
+
+	violations := ValidateSchedule(week, ws, tt, employees, ava)
+	violations = append(violations, CheckCompliance(week, ws, cc).Violations()...)
+	return ValidationResult{
+		Valid:      len(violations) == 0,
+		Violations: violations,
+	}, nil
+}
+
+// ValidateSchedule checks ws against the timetable, the availability of the
+// employees and the roles they are skilled for.
+func ValidateSchedule(
+	week time.Time,
+	ws WeekSchedule,
+	tt WeekTimetable,
+	employees []store.Employee,
+	ava map[string]store.WeekAvailability,
+) []Violation {
+	known := make(map[string]store.Employee, len(employees))
+	for _, e := range employees {
+		known[e.Email] = e
+	}
+
+	weekStart := store.StartOfWeek(week)
+	violations := []Violation{}
+	booked := make(map[string][]workShift)
+	for dayIdx, weekday := range Weekdays {
+		date := weekStart.AddDate(0, 0, dayIdx).Format(time.DateOnly)
+		dtt := tt.Day(weekday)
+
+		for _, s := range ws.Day(weekday).Shifts {
+			start, end := shiftInterval(weekStart.AddDate(0, 0, dayIdx), s.From, s.To)
+			work := workShift{start: start, end: end}
+
+			newViolation := func(code string, employee string, msg string) Violation {
+				from, to := work.start, work.end
+				return Violation{
+					Code:     code,
+					Date:     date,
+					Weekday:  weekday.String(),
+					From:     &from,
+					To:       &to,
+					Employee: employee,
+					Message:  msg,
+				}
+			}
+
+			stt, ok := findShift(dtt, s.From, s.To)
+			if !ok {
+				violations = append(violations, newViolation(
+					ViolationUnknownShift,
+					"",
+					fmt.Sprintf("no shift %s-%s in the timetable", s.From, s.To),
+				))
+			} else if len(s.Employees) > stt.RequiredEmployees {
+				violations = append(violations, newViolation(
+					ViolationOverstaffed,
+					"",
+					fmt.Sprintf("%d employees assigned but only %d required", len(s.Employees), stt.RequiredEmployees),
+				))
+			}
+
+			seen := make(map[string]bool, len(s.Employees))
+			for _, email := range s.Employees {
+				if seen[email] {
+					violations = append(violations, newViolation(ViolationDuplicateEmployee, email, "employee is assigned more than once"))
+					continue
+				}
+				seen[email] = true
+
+				if _, ok := known[email]; !ok {
+					violations = append(violations, newViolation(ViolationUnknownEmployee, email, "no employee with this email"))
+					continue
+				}
+
+				day := ava[email].Day(weekday)
+				if day.Availability == "" || day.Availability == store.AvailabilityUnknown {
+					violations = append(violations, newViolation(ViolationUnknownAvailable, email, "availability of employee is unknown for this day"))
+				} else if !coversShift(ava[email], weekday, s.From, s.To) {
+					violations = append(violations, newViolation(ViolationUnavailable, email, "employee is not available for the whole shift"))
+				}
+
+				for _, other := range booked[email] {
+					if overlaps(other, work) {
+						violations = append(violations, newViolation(
+							ViolationOverlappingShifts,
+							email,
+							fmt.Sprintf("overlaps shift %s-%s", other.start.Format("2006-01-02 15:04"), other.end.Format("2006-01-02 15:04")),
+						))
+					}
+				}
+				booked[email] = append(booked[email], work)
+			}
+
+			for _, role := range sortedKeys(s.Roles) {
+				emails := s.Roles[role]
+				if ok && stt.Roles[role] == 0 {
+					violations = append(violations, newViolation(ViolationUnknownRole, "", fmt.Sprintf("role %q is not required by the shift", role)))
+				} else if ok && len(emails) > stt.Roles[role] {
+					violations = append(violations, newViolation(
+						ViolationRoleOverstaffed,
+						"",
+						fmt.Sprintf("%d employees work as %s but only %d required", len(emails), role, stt.Roles[role]),
+					))
+				}
+
+				for _, email := range emails {
+					if !seen[email] {
+						violations = append(violations, newViolation(ViolationRoleNotInShift, email, fmt.Sprintf("works as %s but is not assigned to the shift", role)))
+						continue
+					}
+					if e, exists := known[email]; exists && !e.HasSkill(role) {
+						violations = append(violations, newViolation(ViolationMissingSkill, email, fmt.Sprintf("employee does not have the skill %q", role)))
+					}
+				}
+			}
+		}
+	}
+
+	return violations
+}
+
+func findShift(dtt DayTimetable, from store.Clock, to store.Clock) (ShiftTimetable, bool) {
+	for _, s := range dtt.Shifts {
+		if s.From == from && s.To == to {
+			return s, true
+		}
+	}
+	return ShiftTimetable{}, false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
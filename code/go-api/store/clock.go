@@ -0,0 +1,74 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Clock is a time of day as minutes after midnight, independent of any date
+// or time zone. It is encoded in JSON as "15:04".
+type Clock int
+
+func NewClock(hour int, minute int) Clock {
+	return Clock(hour*60 + minute)
+}
+
+func ParseClock(s string) (Clock, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return ClockOf(t), nil
+}
+
+// ClockOf returns the wall clock time of t in its own location.
+func ClockOf(t time.Time) Clock {
+	return NewClock(t.Hour(), t.Minute())
+}
+
+func (c Clock) Hour() int {
+	return int(c) / 60
+}
+
+func (c Clock) Minute() int {
+	return int(c) % 60
+}
+
+func (c Clock) String() string {
+	return fmt.Sprintf("%02d:%02d", c.Hour(), c.Minute())
+}
+
+// On returns the instant the clock shows c on the day of date, in the
+// location of date.
+func (c Clock) On(date time.Time) time.Time {
+	y, m, d := date.Date()
+	return time.Date(y, m, d, c.Hour(), c.Minute(), 0, 0, date.Location())
+}
+
+func (c Clock) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+// UnmarshalJSON accepts "15:04" as well as full timestamps, which is how
+// times of day used to be stored.
+func (c *Clock) UnmarshalJSON(data []byte) error {
+	var s string
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := ParseClock(s)
+	if err == nil {
+		*c = parsed
+		return nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return fmt.Errorf("invalid time of day %q, expected format is HH:MM", s)
+	}
+	*c = ClockOf(t)
+	return nil
+}
@@ -2,9 +2,10 @@ package store
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"math/rand"
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/log"
@@ -13,6 +14,7 @@ import (
 
 var (
 	ErrEmployeeAlreadyExists = gocb.ErrDocumentExists
+	ErrEmployeeNotFound      = gocb.ErrDocumentNotFound
 )
 
 type EmployeeStore struct {
@@ -20,10 +22,13 @@ type EmployeeStore struct {
 	scope  *gocb.Scope
 	col    *gocb.Collection
 	avaCol *gocb.Collection
-	logger *log.Logger
+	// leaveCol holds leave requests, keyed by ID.
+	leaveCol *gocb.Collection
+	tz       *TimeZone
+	logger   *log.Logger
 }
 
-func NewEmployeeStore(bucket *gocb.Bucket, logger *log.Logger) EmployeeStore {
+func NewEmployeeStore(bucket *gocb.Bucket, tz *TimeZone, logger *log.Logger) EmployeeStore {
 	err := bucket.CollectionsV2().CreateScope("employees", &gocb.CreateScopeOptions{})
 	if err != nil && !errors.Is(err, gocb.ErrScopeExists) {
 		logger.Fatal("failed to create scope", "err", err)
@@ -42,21 +47,82 @@ func NewEmployeeStore(bucket *gocb.Bucket, logger *log.Logger) EmployeeStore {
 	}
 	avaCol := scope.Collection("availability")
 
+	err = bucket.CollectionsV2().CreateCollection(scope.Name(), "leave_requests", &gocb.CreateCollectionSettings{}, &gocb.CreateCollectionOptions{})
+	if err != nil && !errors.Is(err, gocb.ErrCollectionExists) {
+		logger.Fatal("failed to create collection", "err", err)
+	}
+	leaveCol := scope.Collection("leave_requests")
+
 	return EmployeeStore{
-		bucket: bucket,
-		scope:  scope,
-		col:    col,
-		logger: logger,
-		avaCol: avaCol,
+		bucket:   bucket,
+		scope:    scope,
+		col:      col,
+		logger:   logger,
+		avaCol:   avaCol,
+		leaveCol: leaveCol,
+		tz:       tz,
 	}
 }
 
+// Location is the time zone of the business that availability is expressed
+// in.
+func (es *EmployeeStore) Location() *time.Location {
+	return es.tz.Location()
+}
+
 type Employee struct {
 	Name             string `json:"name"`
 	Email            string `json:"email"`
 	Address          string `json:"address"`
 	DateOfBirth      int64  `json:"date_of_birth"`
 	EmergencyContact int64  `json:"emergency_contact"`
+	// Skills are the roles the employee is qualified to work, e.g. "cook".
+	Skills []string `json:"skills,omitempty"`
+	// HireDate is when the employment started, as a Unix timestamp of
+	// midnight UTC. Zero if unknown.
+	HireDate int64 `json:"hire_date,omitempty"`
+	// HourlyWage is what an hour of work costs, in minor units of the
+	// business currency. Zero if unknown.
+	HourlyWage int64 `json:"hourly_wage,omitempty"`
+	// Agreement names the collective agreement whose pay supplements apply
+	// to the employee. Empty means the default agreement of the business.
+	Agreement string `json:"agreement,omitempty"`
+}
+
+func (e Employee) HasSkill(skill string) bool {
+	for _, s := range e.Skills {
+		if s == skill {
+			return true
+		}
+	}
+	return false
+}
+
+func (es *EmployeeStore) SetSkills(ctx context.Context, email string, skills []string) error {
+	_, err := es.col.MutateIn(email, []gocb.MutateInSpec{
+		gocb.UpsertSpec("skills", skills, &gocb.UpsertSpecOptions{}),
+	}, &gocb.MutateInOptions{
+		Context: ctx,
+	})
+	return err
+}
+
+func (es *EmployeeStore) SetHourlyWage(ctx context.Context, email string, wage int64) error {
+	_, err := es.col.MutateIn(email, []gocb.MutateInSpec{
+		gocb.UpsertSpec("hourly_wage", wage, &gocb.UpsertSpecOptions{}),
+	}, &gocb.MutateInOptions{
+		Context: ctx,
+	})
+	return err
+}
+
+func (es *EmployeeStore) SetAgreement(ctx context.Context, email string, agreement string) error {
+	_, err := es.col.MutateIn(email, []gocb.MutateInSpec{
+		gocb.UpsertSpec("agreement", agreement, &gocb.UpsertSpecOptions{}),
+	}, &gocb.MutateInOptions{
+		Context: ctx,
+	})
+	return err
 }
 
 func (es *EmployeeStore) Create(ctx context.Context, e Employee) error {
@@ -67,8 +133,10 @@ func (es *EmployeeStore) Create(ctx context.Context, e Employee) error {
 		return err
 	}
 
-	ava := generateDefaultAvailability()
-	es.logger.Print(ava)
+	ava := EmployeeAvailability{
+		Patterns: []AvailabilityPattern{defaultAvailabilityPattern(time.Now().In(es.Location()))},
+		Weeks:    map[string]WeekAvailability{},
+	}
 	_, err = es.avaCol.Upsert(e.Email, ava, &gocb.UpsertOptions{
 		Context: ctx,
 	})
@@ -126,24 +194,79 @@ func (es *EmployeeStore) All(ctx context.Context) ([]Employee, error) {
 	return employees, nil
 }
 
+// Availability returns the availability of an employee along with their
+// approved leave.
 func (es *EmployeeStore) Availability(ctx context.Context, email string) (EmployeeAvailability, error) {
-	res, err := es.avaCol.Get(email, &gocb.GetOptions{
-		Context: ctx,
-	})
+	ava, err := es.storedAvailability(ctx, email)
 	if err != nil {
 		return EmployeeAvailability{}, err
 	}
 
-	var ava EmployeeAvailability
-	err = res.Content(&ava)
+	ava.Leave, err = es.LeaveRequests(ctx, LeaveFilter{
+		Employee: email,
+		Status:   LeaveApproved,
+	})
 	return ava, err
 }
 
+// storedAvailability returns the availability of an employee without leave.
+// Availability stored before patterns is replaced by the default pattern.
+func (es *EmployeeStore) storedAvailability(ctx context.Context, email string) (EmployeeAvailability, error) {
+	for {
+		res, err := es.avaCol.Get(email, &gocb.GetOptions{
+			Context: ctx,
+		})
+		if err != nil {
+			return EmployeeAvailability{}, err
+		}
+
+		var fields map[string]json.RawMessage
+		err = res.Content(&fields)
+		if err != nil {
+			return EmployeeAvailability{}, err
+		}
+		var ava EmployeeAvailability
+		err = res.Content(&ava)
+		if err != nil {
+			return EmployeeAvailability{}, err
+		}
+		if _, ok := fields["patterns"]; ok {
+			return ava, nil
+		}
+
+		ava = EmployeeAvailability{
+			Patterns: []AvailabilityPattern{defaultAvailabilityPattern(time.Now().In(es.Location()))},
+			Weeks:    map[string]WeekAvailability{},
+		}
+		_, err = es.avaCol.Replace(email, ava, &gocb.ReplaceOptions{
+			Context: ctx,
+			Cas:     res.Cas(),
+		})
+		if errors.Is(err, gocb.ErrCasMismatch) {
+			continue
+		}
+		return ava, err
+	}
+}
+
+const (
+	AvailabilityAvailable   = "available"
+	AvailabilityUnavailable = "unavailable"
+	AvailabilityPartial     = "partial"
+	// AvailabilityUnknown marks days that no pattern or explicit week covers.
+	AvailabilityUnknown = "unknown"
+)
+
 type DayAvilability struct {
 	Date         time.Time  `json:"date"`
-	Availability string     `json:"availability"` // "available", "unavailable", "partial"
+	Availability string     `json:"availability"` // "available", "unavailable", "partial", "unknown"
 	From         *time.Time `json:"from,omitempty"`
 	To           *time.Time `json:"to,omitempty"`
+	// Windows refine Availability for parts of the day, see Covers.
+	Windows []AvailabilityWindow `json:"windows,omitempty"`
+	// Leave is the type of approved leave that made the employee
+	// unavailable for all or part of the day, if any.
+	Leave string `json:"leave,omitempty"`
 }
 
 type WeekAvailability struct {
@@ -155,66 +278,94 @@ type WeekAvailability struct {
 	Friday    DayAvilability `json:"friday"`
 	Saturday  DayAvilability `json:"saturday"`
 	Sunday    DayAvilability `json:"sunday"`
+	// NextMonday is the Monday of the following week, which overnight shifts
+	// starting on Sunday end on. Only filled in when materialized, never
+	// stored.
+	NextMonday *DayAvilability `json:"-"`
 }
 
-type EmployeeAvailability struct {
-	Weeks map[string]WeekAvailability `json:"weeks"`
-}
-
-func generateDefaultAvailability() EmployeeAvailability {
-	from := time.Now()
-	to := from.AddDate(1, 0, 0)
-	startYear, startWeek := from.ISOWeek()
-	endYear, endWeek := to.ISOWeek()
-
-	weeks := make(map[string]WeekAvailability, endWeek-startWeek+1)
-	yearDiff := endYear - startYear
-	absEndWeek := endWeek + yearDiff*52
-	for i := startWeek; i <= absEndWeek; i++ {
-		adjustedWeekNr := i % 52
-		addYears := i / 52
-		week := from.AddDate(addYears, 0, 7*(adjustedWeekNr-startWeek))
-		weekDateStr := week.Format("2006-01-02")
-		_, weekNr := week.ISOWeek()
-		humanReadWeekStr := fmt.Sprintf("Week %d", weekNr)
-
-		weeks[weekDateStr] = WeekAvailability{
-			WeekStr:   humanReadWeekStr,
-			Monday:    getDefaultDayAvailability(week, "available"),
-			Tuesday:   getDefaultDayAvailability(week.AddDate(0, 0, 1), randomAvailability()),
-			Wednesday: getDefaultDayAvailability(week.AddDate(0, 0, 2), randomAvailability()),
-			Thursday:  getDefaultDayAvailability(week.AddDate(0, 0, 3), randomAvailability()),
-			Friday:    getDefaultDayAvailability(week.AddDate(0, 0, 4), randomAvailability()),
-			Saturday:  getDefaultDayAvailability(week.AddDate(0, 0, 5), randomAvailability()),
-			Sunday:    getDefaultDayAvailability(week.AddDate(0, 0, 6), "partial"),
-		}
+func (wa WeekAvailability) Day(weekday time.Weekday) DayAvilability {
+	switch weekday {
+	case time.Monday:
+		return wa.Monday
+	case time.Tuesday:
+		return wa.Tuesday
+	case time.Wednesday:
+		return wa.Wednesday
+	case time.Thursday:
+		return wa.Thursday
+	case time.Friday:
+		return wa.Friday
+	case time.Saturday:
+		return wa.Saturday
+	default:
+		return wa.Sunday
 	}
+}
 
-	return EmployeeAvailability{
-		Weeks: weeks,
+// NextDay returns the day after weekday. For Sunday that is NextMonday, or an
+// unknown day if the following week was not materialized.
+func (wa WeekAvailability) NextDay(weekday time.Weekday) DayAvilability {
+	if weekday != time.Sunday {
+		return wa.Day(weekday + 1)
 	}
+	if wa.NextMonday == nil {
+		return DayAvilability{Availability: AvailabilityUnknown}
+	}
+	return *wa.NextMonday
 }
 
-func randomAvailability() string {
-	availabilities := []string{"available", "unavailable", "partial"}
-	return availabilities[rand.Intn(len(availabilities))]
+// EmployeeAvailability holds the recurring availability patterns of an
+// employee together with weeks that override them, keyed by the date of their
+// Monday. Approved leave overrides both.
+type EmployeeAvailability struct {
+	Patterns []AvailabilityPattern       `json:"patterns,omitempty"`
+	Weeks    map[string]WeekAvailability `json:"weeks"`
+	// Leave is the approved leave of the employee. Only filled in when
+	// loaded, never stored with the availability.
+	Leave []LeaveRequest `json:"leave,omitempty"`
 }
 
-func getDefaultDayAvailability(date time.Time, ava string) DayAvilability {
-	var from *time.Time
-	var to *time.Time
-	if ava == "partial" {
-		f := time.Now()
-		t := f.Add(8 * time.Hour)
-		from = &f
-		to = &t
+// SetAvailabilityForWeek stores the given days of the week containing week
+// without touching the other days. The week is created if it does not exist
+// yet. The stored week is returned.
+func (es *EmployeeStore) SetAvailabilityForWeek(ctx context.Context, email string, week time.Time, days map[time.Weekday]DayAvilability) (WeekAvailability, error) {
+	week = StartOfWeek(week)
+	weekDateStr := week.Format("2006-01-02")
+	weekPath := fmt.Sprintf("weeks.`%s`", weekDateStr)
+	_, weekNr := week.ISOWeek()
+
+	specs := []gocb.MutateInSpec{
+		gocb.UpsertSpec(weekPath+".weekStr", fmt.Sprintf("Week %d", weekNr), &gocb.UpsertSpecOptions{
+			CreatePath: true,
+		}),
 	}
-	return DayAvilability{
-		Date:         date,
-		Availability: ava,
-		From:         from,
-		To:           to,
+	for weekday, da := range days {
+		path := fmt.Sprintf("%s.%s", weekPath, strings.ToLower(weekday.String()))
+		specs = append(specs, gocb.UpsertSpec(path, da, &gocb.UpsertSpecOptions{
+			CreatePath: true,
+		}))
 	}
+
+	_, err := es.avaCol.MutateIn(email, specs, &gocb.MutateInOptions{
+		Context: ctx,
+	})
+	if err != nil {
+		return WeekAvailability{}, err
+	}
+
+	res, err := es.avaCol.LookupIn(email, []gocb.LookupInSpec{
+		gocb.GetSpec(weekPath, &gocb.GetSpecOptions{}),
+	}, &gocb.LookupInOptions{
+		Context: ctx,
+	})
+	if err != nil {
+		return WeekAvailability{}, err
+	}
+
+	var wa WeekAvailability
+	err = res.ContentAt(0, &wa)
+	return wa, err
 }
 
 type EmployeesWeekAvailability struct {
@@ -222,34 +373,56 @@ type EmployeesWeekAvailability struct {
 }
 
 func (es *EmployeeStore) GetAllEmployeesAvailabilityForWeek(ctx context.Context, week time.Time) (EmployeeAvailability, error) {
-	allEmployees, err := es.All(ctx)
+	weeks, err := es.GetAllEmployeesAvailabilityForWeeks(ctx, week, week)
 	if err != nil {
 		return EmployeeAvailability{}, err
 	}
 
-	allEmails := make([]string, 0, len(allEmployees))
-	for _, e := range allEmployees {
-		allEmails = append(allEmails, e.Email)
+	return EmployeeAvailability{
+		Weeks: weeks[StartOfWeek(week).Format("2006-01-02")],
+	}, nil
+}
+
+// GetAllEmployeesAvailabilityForWeeks materializes the availability of every
+// employee from the week containing from to the week containing to.
+func (es *EmployeeStore) GetAllEmployeesAvailabilityForWeeks(ctx context.Context, from time.Time, to time.Time) (map[string]map[string]WeekAvailability, error) {
+	allEmployees, err := es.All(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	weeks := make(map[string]WeekAvailability, len(allEmails))
-	weekDateStr := week.Format("2006-01-02")
-	for _, email := range allEmails {
-		ava, err := es.Availability(ctx, email)
-		if err != nil {
-			return EmployeeAvailability{}, err
-		}
+	first, last := StartOfWeek(from), StartOfWeek(to)
+	// the Monday after the last week is materialized as well
+	leave, err := es.LeaveRequests(ctx, LeaveFilter{
+		Status: LeaveApproved,
+		From:   first.Format("2006-01-02"),
+		To:     last.AddDate(0, 0, 7).Format("2006-01-02"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	leaveByEmployee := make(map[string][]LeaveRequest)
+	for _, lr := range leave {
+		leaveByEmployee[lr.Employee] = append(leaveByEmployee[lr.Employee], lr)
+	}
 
-		weekAva, ok := ava.Weeks[weekDateStr]
-		if !ok {
-			es.logger.Print("week not found", "week", weekDateStr, "email", email)
-			return EmployeeAvailability{}, fmt.Errorf("week not found for employee %s", email)
+	weeks := make(map[string]map[string]WeekAvailability)
+	for week := first; !week.After(last); week = week.AddDate(0, 0, 7) {
+		weeks[week.Format("2006-01-02")] = make(map[string]WeekAvailability, len(allEmployees))
+	}
+	for _, e := range allEmployees {
+		ava, err := es.storedAvailability(ctx, e.Email)
+		if errors.Is(err, gocb.ErrDocumentNotFound) {
+			es.logger.Print("no availability for employee", "email", e.Email)
+		} else if err != nil {
+			return nil, err
 		}
+		ava.Leave = leaveByEmployee[e.Email]
 
-		weeks[email] = weekAva
+		for week := first; !week.After(last); week = week.AddDate(0, 0, 7) {
+			weeks[week.Format("2006-01-02")][e.Email] = ava.Week(week)
+		}
 	}
 
-	return EmployeeAvailability{
-		Weeks: weeks,
-	}, nil
+	return weeks, nil
 }
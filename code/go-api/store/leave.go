@@ -0,0 +1,303 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	LeaveVacation = "vacation"
+	LeaveSick     = "sick"
+	LeaveParental = "parental"
+	LeaveUnpaid   = "unpaid"
+	LeaveOther    = "other"
+)
+
+// LeaveTypes lists the kinds of leave employees can request.
+var LeaveTypes = []string{LeaveVacation, LeaveSick, LeaveParental, LeaveUnpaid, LeaveOther}
+
+const (
+	LeavePending   = "pending"
+	LeaveApproved  = "approved"
+	LeaveRejected  = "rejected"
+	LeaveCancelled = "cancelled"
+)
+
+var (
+	ErrLeaveNotFound    = gocb.ErrDocumentNotFound
+	ErrLeaveClosed      = errors.New("leave request can no longer be changed")
+	ErrLeaveChanged     = errors.New("leave request was changed at the same time, try again")
+	ErrLeaveOverlaps    = errors.New("leave overlaps other leave of the employee")
+	ErrInvalidLeaveType = errors.New("unknown leave type")
+)
+
+// LeaveHours limits leave to part of each day. To must be after From.
+type LeaveHours struct {
+	From Clock `json:"from"`
+	To   Clock `json:"to"`
+}
+
+// LeaveRequest is an employee asking for leave on every day from From up to
+// and including To, formatted as 2006-01-02. Approved leave overrides the
+// availability of the employee on those days.
+type LeaveRequest struct {
+	ID       string `json:"id"`
+	Employee string `json:"employee"`
+	Type     string `json:"type"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	// Hours is set for partial days, e.g. a doctor's appointment. Nil means
+	// whole days.
+	Hours     *LeaveHours `json:"hours,omitempty"`
+	Note      string      `json:"note,omitempty"`
+	Status    string      `json:"status"`
+	CreatedAt time.Time   `json:"createdAt"`
+	DecidedBy string      `json:"decidedBy,omitempty"`
+	DecidedAt *time.Time  `json:"decidedAt,omitempty"`
+}
+
+// Covers reports whether date is one of the days of the leave.
+func (lr LeaveRequest) Covers(date time.Time) bool {
+	d := date.Format(time.DateOnly)
+	return d >= lr.From && d <= lr.To
+}
+
+// Interval returns when the leave starts and ends on date, in the location of
+// date.
+func (lr LeaveRequest) Interval(date time.Time) (time.Time, time.Time) {
+	if lr.Hours == nil {
+		start := startOfDay(date)
+		return start, start.AddDate(0, 0, 1)
+	}
+	return lr.Hours.From.On(date), lr.Hours.To.On(date)
+}
+
+// active reports whether the leave is, or may become, approved.
+func (lr LeaveRequest) active() bool {
+	return lr.Status == LeavePending || lr.Status == LeaveApproved
+}
+
+func (lr LeaveRequest) overlaps(other LeaveRequest) bool {
+	if lr.From > other.To || other.From > lr.To {
+		return false
+	}
+	if lr.Hours == nil || other.Hours == nil {
+		return true
+	}
+	return lr.Hours.From < other.Hours.To && other.Hours.From < lr.Hours.To
+}
+
+// apply returns da with the leave taken out. Partial leave becomes an
+// unavailable window of the day.
+func (lr LeaveRequest) apply(da DayAvilability) DayAvilability {
+	if lr.Hours == nil {
+		return DayAvilability{
+			Date:         da.Date,
+			Availability: AvailabilityUnavailable,
+			Leave:        lr.Type,
+		}
+	}
+	if da.Availability != AvailabilityAvailable && da.Availability != AvailabilityPartial {
+		return da
+	}
+
+	da.Windows = append(slices.Clone(da.Windows), AvailabilityWindow{
+		From:  lr.Hours.From,
+		To:    lr.Hours.To,
+		Level: LevelUnavailable,
+	})
+	da.Leave = lr.Type
+	return da
+}
+
+// CreateLeave stores a new pending leave request. It must not overlap other
+// pending or approved leave of the employee.
+func (es *EmployeeStore) CreateLeave(ctx context.Context, lr LeaveRequest) (LeaveRequest, error) {
+	if !isLeaveType(lr.Type) {
+		return LeaveRequest{}, ErrInvalidLeaveType
+	}
+	if lr.To < lr.From {
+		return LeaveRequest{}, errors.New("to must not be before from")
+	}
+	if lr.Hours != nil && lr.Hours.To <= lr.Hours.From {
+		return LeaveRequest{}, errors.New("leave hours must end after they start")
+	}
+
+	_, err := es.Get(ctx, lr.Employee)
+	if err != nil {
+		return LeaveRequest{}, err
+	}
+
+	existing, err := es.LeaveRequests(ctx, LeaveFilter{
+		Employee: lr.Employee,
+		From:     lr.From,
+		To:       lr.To,
+	})
+	if err != nil {
+		return LeaveRequest{}, err
+	}
+	for _, other := range existing {
+		if other.active() && lr.overlaps(other) {
+			return LeaveRequest{}, ErrLeaveOverlaps
+		}
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return LeaveRequest{}, err
+	}
+	lr.ID = id.String()
+	lr.Status = LeavePending
+	lr.CreatedAt = time.Now().In(es.Location())
+
+	_, err = es.leaveCol.Insert(lr.ID, lr, &gocb.InsertOptions{
+		Context: ctx,
+	})
+	return lr, err
+}
+
+func isLeaveType(t string) bool {
+	for _, lt := range LeaveTypes {
+		if lt == t {
+			return true
+		}
+	}
+	return false
+}
+
+func (es *EmployeeStore) GetLeave(ctx context.Context, id string) (LeaveRequest, error) {
+	lr, _, err := es.getLeaveForUpdate(ctx, id)
+	return lr, err
+}
+
+func (es *EmployeeStore) getLeaveForUpdate(ctx context.Context, id string) (LeaveRequest, gocb.Cas, error) {
+	res, err := es.leaveCol.Get(id, &gocb.GetOptions{
+		Context: ctx,
+	})
+	if err != nil {
+		return LeaveRequest{}, 0, err
+	}
+
+	var lr LeaveRequest
+	err = res.Content(&lr)
+	return lr, res.Cas(), err
+}
+
+// replaceLeave writes lr if it has not changed since it was read with cas, and
+// returns ErrLeaveChanged otherwise.
+func (es *EmployeeStore) replaceLeave(ctx context.Context, lr LeaveRequest, cas gocb.Cas) error {
+	_, err := es.leaveCol.Replace(lr.ID, lr, &gocb.ReplaceOptions{
+		Context: ctx,
+		Cas:     cas,
+	})
+	if errors.Is(err, gocb.ErrCasMismatch) {
+		return ErrLeaveChanged
+	}
+	return err
+}
+
+// LeaveFilter narrows down leave requests. Empty fields match everything.
+// Leave matches From and To if any of its days lies between them.
+type LeaveFilter struct {
+	Employee string
+	Status   string
+	From     string
+	To       string
+}
+
+// LeaveRequests returns the leave requests matching f, ordered by their
+// first day.
+func (es *EmployeeStore) LeaveRequests(ctx context.Context, f LeaveFilter) ([]LeaveRequest, error) {
+	conditions := []string{"TRUE"}
+	params := make(map[string]interface{})
+	if f.Employee != "" {
+		conditions = append(conditions, "x.employee = $employee")
+		params["employee"] = f.Employee
+	}
+	if f.Status != "" {
+		conditions = append(conditions, "x.status = $status")
+		params["status"] = f.Status
+	}
+	if f.From != "" {
+		conditions = append(conditions, "x.`to` >= $from")
+		params["from"] = f.From
+	}
+	if f.To != "" {
+		conditions = append(conditions, "x.`from` <= $to")
+		params["to"] = f.To
+	}
+
+	res, err := es.scope.Query(
+		"SELECT x.* FROM leave_requests x WHERE "+strings.Join(conditions, " AND ")+" ORDER BY x.`from`, x.createdAt",
+		&gocb.QueryOptions{
+			Context:         ctx,
+			NamedParameters: params,
+			// leave that was just requested must be seen when checking for
+			// overlaps
+			ScanConsistency: gocb.QueryScanConsistencyRequestPlus,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close() // ignore error
+
+	requests := []LeaveRequest{}
+	for res.Next() {
+		var lr LeaveRequest
+		err := res.Row(&lr)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, lr)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+	}
+
+	return requests, res.Err()
+}
+
+// DecideLeave approves or rejects a pending leave request.
+func (es *EmployeeStore) DecideLeave(ctx context.Context, id string, approve bool, actor string) (LeaveRequest, error) {
+	lr, cas, err := es.getLeaveForUpdate(ctx, id)
+	if err != nil {
+		return LeaveRequest{}, err
+	}
+	if lr.Status != LeavePending {
+		return LeaveRequest{}, ErrLeaveClosed
+	}
+
+	now := time.Now().In(es.Location())
+	lr.Status = LeaveRejected
+	if approve {
+		lr.Status = LeaveApproved
+	}
+	lr.DecidedBy = actor
+	lr.DecidedAt = &now
+	return lr, es.replaceLeave(ctx, lr, cas)
+}
+
+// CancelLeave withdraws pending or approved leave. The availability of the
+// employee on the days of cancelled leave is no longer affected by it.
+func (es *EmployeeStore) CancelLeave(ctx context.Context, id string) (LeaveRequest, error) {
+	lr, cas, err := es.getLeaveForUpdate(ctx, id)
+	if err != nil {
+		return LeaveRequest{}, err
+	}
+	if !lr.active() {
+		return LeaveRequest{}, ErrLeaveClosed
+	}
+
+	lr.Status = LeaveCancelled
+	return lr, es.replaceLeave(ctx, lr, cas)
+}
@@ -0,0 +1,211 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+// DayPattern is the recurring availability of one weekday. From and To are
+// only used for partial days, which may use Windows instead.
+type DayPattern struct {
+	Availability string               `json:"availability"`
+	From         Clock                `json:"from,omitempty"`
+	To           Clock                `json:"to,omitempty"`
+	Windows      []AvailabilityWindow `json:"windows,omitempty"`
+}
+
+// AvailabilityPattern repeats every week from EffectiveFrom up to and
+// including EffectiveTo. A nil EffectiveTo means the pattern has no end.
+type AvailabilityPattern struct {
+	EffectiveFrom time.Time  `json:"effectiveFrom"`
+	EffectiveTo   *time.Time `json:"effectiveTo,omitempty"`
+	Monday        DayPattern `json:"monday"`
+	Tuesday       DayPattern `json:"tuesday"`
+	Wednesday     DayPattern `json:"wednesday"`
+	Thursday      DayPattern `json:"thursday"`
+	Friday        DayPattern `json:"friday"`
+	Saturday      DayPattern `json:"saturday"`
+	Sunday        DayPattern `json:"sunday"`
+}
+
+func (p AvailabilityPattern) Day(weekday time.Weekday) DayPattern {
+	switch weekday {
+	case time.Monday:
+		return p.Monday
+	case time.Tuesday:
+		return p.Tuesday
+	case time.Wednesday:
+		return p.Wednesday
+	case time.Thursday:
+		return p.Thursday
+	case time.Friday:
+		return p.Friday
+	case time.Saturday:
+		return p.Saturday
+	default:
+		return p.Sunday
+	}
+}
+
+// covers reports whether the pattern is in effect on date. The effective
+// dates are compared as dates in the location of date.
+func (p AvailabilityPattern) covers(date time.Time) bool {
+	loc := date.Location()
+	if date.Before(startOfDay(p.EffectiveFrom.In(loc))) {
+		return false
+	}
+	return p.EffectiveTo == nil || !date.After(startOfDay(p.EffectiveTo.In(loc)))
+}
+
+func (dp DayPattern) materialize(date time.Time) DayAvilability {
+	day := DayAvilability{
+		Date:         date,
+		Availability: dp.Availability,
+		Windows:      dp.Windows,
+	}
+	if dp.Availability != AvailabilityPartial || dp.From == dp.To {
+		return day
+	}
+
+	from := dp.From.On(date)
+	to := dp.To.On(date)
+	day.From = &from
+	day.To = &to
+	return day
+}
+
+func defaultAvailabilityPattern(from time.Time) AvailabilityPattern {
+	available := DayPattern{
+		Availability: AvailabilityAvailable,
+	}
+	return AvailabilityPattern{
+		EffectiveFrom: startOfDay(from),
+		Monday:        available,
+		Tuesday:       available,
+		Wednesday:     available,
+		Thursday:      available,
+		Friday:        available,
+		Saturday:      available,
+		Sunday:        available,
+	}
+}
+
+// Week materializes the week containing the given date. Days set in Weeks win
+// over the patterns, and among the patterns the latest one wins.
+func (ea EmployeeAvailability) Week(week time.Time) WeekAvailability {
+	weekStart := StartOfWeek(week)
+	wa := ea.materializeWeek(weekStart)
+	next := ea.materializeWeek(weekStart.AddDate(0, 0, 7)).Monday
+	wa.NextMonday = &next
+	return wa
+}
+
+func (ea EmployeeAvailability) materializeWeek(weekStart time.Time) WeekAvailability {
+	override := ea.Weeks[weekStart.Format("2006-01-02")]
+
+	patterns := make([]AvailabilityPattern, len(ea.Patterns))
+	copy(patterns, ea.Patterns)
+	sort.SliceStable(patterns, func(i, j int) bool {
+		return patterns[i].EffectiveFrom.After(patterns[j].EffectiveFrom)
+	})
+
+	_, weekNr := weekStart.ISOWeek()
+	wa := WeekAvailability{
+		WeekStr: fmt.Sprintf("Week %d", weekNr),
+	}
+	for i, weekday := range []time.Weekday{
+		time.Monday,
+		time.Tuesday,
+		time.Wednesday,
+		time.Thursday,
+		time.Friday,
+		time.Saturday,
+		time.Sunday,
+	} {
+		date := weekStart.AddDate(0, 0, i)
+
+		day := override.Day(weekday)
+		if day.Availability == "" {
+			day = DayAvilability{
+				Date:         date,
+				Availability: AvailabilityUnknown,
+			}
+			for _, p := range patterns {
+				if !p.covers(date) {
+					continue
+				}
+				day = p.Day(weekday).materialize(date)
+				break
+			}
+		}
+
+		for _, lr := range ea.Leave {
+			if lr.Status == LeaveApproved && lr.Covers(date) {
+				day = lr.apply(day)
+			}
+		}
+
+		wa.setDay(weekday, day)
+	}
+
+	return wa
+}
+
+func (wa *WeekAvailability) setDay(weekday time.Weekday, da DayAvilability) {
+	switch weekday {
+	case time.Monday:
+		wa.Monday = da
+	case time.Tuesday:
+		wa.Tuesday = da
+	case time.Wednesday:
+		wa.Wednesday = da
+	case time.Thursday:
+		wa.Thursday = da
+	case time.Friday:
+		wa.Friday = da
+	case time.Saturday:
+		wa.Saturday = da
+	default:
+		wa.Sunday = da
+	}
+}
+
+// Materialize materializes every week from the week containing from up to and
+// including the week containing to, keyed by the date of their Monday.
+func (ea EmployeeAvailability) Materialize(from time.Time, to time.Time) EmployeeAvailability {
+	weeks := make(map[string]WeekAvailability)
+	for week := StartOfWeek(from); !week.After(to); week = week.AddDate(0, 0, 7) {
+		weeks[week.Format("2006-01-02")] = ea.Week(week)
+	}
+
+	return EmployeeAvailability{
+		Patterns: ea.Patterns,
+		Weeks:    weeks,
+		Leave:    ea.Leave,
+	}
+}
+
+func (es *EmployeeStore) SetAvailabilityPatterns(ctx context.Context, email string, patterns []AvailabilityPattern) error {
+	_, err := es.avaCol.MutateIn(email, []gocb.MutateInSpec{
+		gocb.UpsertSpec("patterns", patterns, &gocb.UpsertSpecOptions{}),
+	}, &gocb.MutateInOptions{
+		Context: ctx,
+	})
+	return err
+}
+
+// StartOfWeek returns midnight of the Monday of the week containing t.
+func StartOfWeek(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	y, m, d := t.Date()
+	return time.Date(y, m, d-offset, 0, 0, 0, 0, t.Location())
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
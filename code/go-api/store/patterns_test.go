@@ -0,0 +1,202 @@
+package store
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// testWeek is a Monday.
+var testWeek = time.Date(2026, time.October, 12, 0, 0, 0, 0, time.UTC)
+
+var weekdays = []time.Weekday{
+	time.Monday,
+	time.Tuesday,
+	time.Wednesday,
+	time.Thursday,
+	time.Friday,
+	time.Saturday,
+	time.Sunday,
+}
+
+func date(s string) time.Time {
+	d, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func clock(s string) Clock {
+	c, err := ParseClock(s)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// pattern returns a pattern with every day set to availability.
+func pattern(from string, to string, availability string) AvailabilityPattern {
+	day := DayPattern{Availability: availability}
+	p := AvailabilityPattern{
+		EffectiveFrom: date(from),
+		Monday:        day,
+		Tuesday:       day,
+		Wednesday:     day,
+		Thursday:      day,
+		Friday:        day,
+		Saturday:      day,
+		Sunday:        day,
+	}
+	if to != "" {
+		end := date(to)
+		p.EffectiveTo = &end
+	}
+	return p
+}
+
+func TestEmployeeAvailabilityWeek(t *testing.T) {
+	const (
+		a = AvailabilityAvailable
+		u = AvailabilityUnavailable
+		p = AvailabilityPartial
+		x = AvailabilityUnknown
+	)
+
+	tests := []struct {
+		name string
+		ea   EmployeeAvailability
+		// want is the availability of every day from Monday to Sunday
+		want     []string
+		wantNext string
+	}{
+		{
+			name:     "no patterns",
+			want:     []string{x, x, x, x, x, x, x},
+			wantNext: x,
+		},
+		{
+			name:     "pattern in effect",
+			ea:       EmployeeAvailability{Patterns: []AvailabilityPattern{pattern("2026-01-01", "", a)}},
+			want:     []string{a, a, a, a, a, a, a},
+			wantNext: a,
+		},
+		{
+			name: "latest pattern wins",
+			ea: EmployeeAvailability{Patterns: []AvailabilityPattern{
+				pattern("2026-10-14", "", u),
+				pattern("2026-01-01", "", a),
+			}},
+			want:     []string{a, a, u, u, u, u, u},
+			wantNext: u,
+		},
+		{
+			name:     "pattern ending within the week",
+			ea:       EmployeeAvailability{Patterns: []AvailabilityPattern{pattern("2026-01-01", "2026-10-13", a)}},
+			want:     []string{a, a, x, x, x, x, x},
+			wantNext: x,
+		},
+		{
+			name: "pattern starting the next week",
+			ea: EmployeeAvailability{Patterns: []AvailabilityPattern{
+				pattern("2026-01-01", "", a),
+				pattern("2026-10-19", "", u),
+			}},
+			want:     []string{a, a, a, a, a, a, a},
+			wantNext: u,
+		},
+		{
+			name: "days set for the week win over patterns",
+			ea: EmployeeAvailability{
+				Patterns: []AvailabilityPattern{pattern("2026-01-01", "", a)},
+				Weeks: map[string]WeekAvailability{
+					"2026-10-12": {Tuesday: DayAvilability{Availability: u}, Sunday: DayAvilability{Availability: p}},
+				},
+			},
+			want:     []string{a, u, a, a, a, a, p},
+			wantNext: a,
+		},
+		{
+			name: "approved leave",
+			ea: EmployeeAvailability{
+				Patterns: []AvailabilityPattern{pattern("2026-01-01", "", a)},
+				Leave: []LeaveRequest{
+					{Type: LeaveVacation, From: "2026-10-15", To: "2026-10-19", Status: LeaveApproved},
+					{Type: LeaveSick, From: "2026-10-12", To: "2026-10-12", Status: LeavePending},
+				},
+			},
+			want:     []string{a, a, a, u, u, u, u},
+			wantNext: u,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			wa := tc.ea.Week(testWeek.AddDate(0, 0, 3))
+
+			var got []string
+			for _, weekday := range weekdays {
+				got = append(got, wa.Day(weekday).Availability)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+			if next := wa.NextDay(time.Sunday).Availability; next != tc.wantNext {
+				t.Errorf("next Monday: got %s, want %s", next, tc.wantNext)
+			}
+		})
+	}
+}
+
+func TestEmployeeAvailabilityWeekPartial(t *testing.T) {
+	p := pattern("2026-01-01", "", AvailabilityAvailable)
+	p.Monday = DayPattern{Availability: AvailabilityPartial, From: clock("09:00"), To: clock("17:00")}
+	p.Tuesday = DayPattern{
+		Availability: AvailabilityAvailable,
+		Windows:      []AvailabilityWindow{{From: clock("08:00"), To: clock("12:00"), Level: LevelPreferred}},
+	}
+	ea := EmployeeAvailability{
+		Patterns: []AvailabilityPattern{p},
+		Leave: []LeaveRequest{{
+			Type:   LeaveOther,
+			From:   "2026-10-13",
+			To:     "2026-10-13",
+			Hours:  &LeaveHours{From: clock("14:00"), To: clock("16:00")},
+			Status: LeaveApproved,
+		}},
+	}
+
+	wa := ea.Week(testWeek)
+	monday := wa.Monday
+	if monday.From == nil || monday.To == nil ||
+		!monday.From.Equal(testWeek.Add(9*time.Hour)) || !monday.To.Equal(testWeek.Add(17*time.Hour)) {
+		t.Errorf("monday: got %v to %v, want 09:00 to 17:00", monday.From, monday.To)
+	}
+
+	tuesday := wa.Tuesday
+	want := []AvailabilityWindow{
+		{From: clock("08:00"), To: clock("12:00"), Level: LevelPreferred},
+		{From: clock("14:00"), To: clock("16:00"), Level: LevelUnavailable},
+	}
+	if tuesday.Availability != AvailabilityAvailable || tuesday.Leave != LeaveOther || !slices.Equal(tuesday.Windows, want) {
+		t.Errorf("tuesday: got %+v, want available with windows %v", tuesday, want)
+	}
+	if len(p.Tuesday.Windows) != 1 {
+		t.Errorf("leave changed the windows of the pattern: %v", p.Tuesday.Windows)
+	}
+}
+
+func TestEmployeeAvailabilityMaterialize(t *testing.T) {
+	ea := EmployeeAvailability{Patterns: []AvailabilityPattern{pattern("2026-01-01", "", AvailabilityAvailable)}}
+
+	got := ea.Materialize(date("2026-10-14"), date("2026-10-28"))
+	var keys []string
+	for key := range got.Weeks {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	want := []string{"2026-10-12", "2026-10-19", "2026-10-26"}
+	if !slices.Equal(keys, want) {
+		t.Errorf("got weeks %v, want %v", keys, want)
+	}
+}
@@ -0,0 +1,33 @@
+package store
+
+import (
+	"sync"
+	"time"
+)
+
+// TimeZone holds the time zone the business operates in. It is shared by the
+// stores so that changing it through the business settings applies to all of
+// them.
+type TimeZone struct {
+	loc *time.Location
+	mu  *sync.RWMutex
+}
+
+func NewTimeZone(loc *time.Location) *TimeZone {
+	return &TimeZone{
+		loc: loc,
+		mu:  &sync.RWMutex{},
+	}
+}
+
+func (tz *TimeZone) Location() *time.Location {
+	tz.mu.RLock()
+	defer tz.mu.RUnlock()
+	return tz.loc
+}
+
+func (tz *TimeZone) Set(loc *time.Location) {
+	tz.mu.Lock()
+	defer tz.mu.Unlock()
+	tz.loc = loc
+}
@@ -0,0 +1,97 @@
+package store
+
+import (
+	"slices"
+)
+
+const (
+	LevelPreferred   = "preferred"
+	LevelAvailable   = "available"
+	LevelUnavailable = "unavailable"
+)
+
+// AvailabilityWindow is a part of a day with a level of availability, e.g.
+// preferred mornings or never between 12:00 and 14:00. A To that is not after
+// From means the window lasts until midnight.
+type AvailabilityWindow struct {
+	From  Clock  `json:"from"`
+	To    Clock  `json:"to"`
+	Level string `json:"level"`
+}
+
+func (w AvailabilityWindow) end() Clock {
+	if w.To <= w.From {
+		return NewClock(24, 0)
+	}
+	return w.To
+}
+
+// windows returns the windows of the day, including the single window that
+// partial days used to be limited to.
+func (da DayAvilability) windows() []AvailabilityWindow {
+	if da.Availability != AvailabilityPartial || da.From == nil || da.To == nil {
+		return da.Windows
+	}
+	return append(slices.Clone(da.Windows), AvailabilityWindow{
+		From:  ClockOf(*da.From),
+		To:    ClockOf(*da.To),
+		Level: LevelAvailable,
+	})
+}
+
+// Covers reports whether the employee can work the whole of the given
+// time-of-day interval on this day. Unavailable windows always win.
+func (da DayAvilability) Covers(from Clock, to Clock) bool {
+	if da.Availability != AvailabilityAvailable && da.Availability != AvailabilityPartial {
+		return false
+	}
+
+	end := to
+	if end <= from {
+		end = NewClock(24, 0)
+	}
+	windows := da.windows()
+	for _, w := range windows {
+		if w.Level == LevelUnavailable && w.From < end && from < w.end() {
+			return false
+		}
+	}
+	if da.Availability == AvailabilityAvailable {
+		return true
+	}
+	return covered(windows, from, end, LevelPreferred, LevelAvailable)
+}
+
+// Level returns how much the employee wants to work the given time-of-day
+// interval: preferred if it lies within preferred windows, available if they
+// can work it at all and unavailable otherwise.
+func (da DayAvilability) Level(from Clock, to Clock) string {
+	if !da.Covers(from, to) {
+		return LevelUnavailable
+	}
+
+	end := to
+	if end <= from {
+		end = NewClock(24, 0)
+	}
+	if covered(da.windows(), from, end, LevelPreferred) {
+		return LevelPreferred
+	}
+	return LevelAvailable
+}
+
+// covered reports whether windows of the given levels together cover the
+// interval from from to end without gaps.
+func covered(windows []AvailabilityWindow, from Clock, end Clock, levels ...string) bool {
+	cur := from
+	for progress := true; progress && cur < end; {
+		progress = false
+		for _, w := range windows {
+			if slices.Contains(levels, w.Level) && w.From <= cur && cur < w.end() {
+				cur = w.end()
+				progress = true
+			}
+		}
+	}
+	return cur >= end
+}
@@ -26,6 +26,7 @@ export enum Availability {
   Available = 'available',
   Unavailable = 'unavailable',
   Partial = 'partial',
+  Unknown = 'unknown',
 }
 
 export async function getEmployeeAvailability(
@@ -27,8 +27,11 @@ export type Shift = {
 }
 
 export function formatShiftTime(t: string): string {
-  // from 0000-01-01T08:00:00Z
-  // to 08:00
+  // shift times are sent as 08:00, older schedules
+  // may still hold 0000-01-01T08:00:00Z
+  if (t.length === 5) {
+    return t
+  }
   t = t.slice(11, 16)
   return t
 }
@@ -14,6 +14,8 @@ const generateBgColor = (availability: string) => {
     return "bg-yellow-200 border-yellow-600 hover:bg-yellow-100";
   } else if (availability === "unavailable") {
     return "bg-gray-200 border-gray-600 hover:bg-gray-100";
+  } else if (availability === "unknown") {
+    return "bg-white border-dashed border-gray-400 hover:bg-gray-50";
   }
 };
 
@@ -70,7 +70,7 @@ type WeekSchedule = {
 
 
 async function getScheduleForWeek(date: string): Promise<WeekSchedule> {
-  const url = `${config.api_base_url}business/schedule/${date}`
+  const url = `${config.api_base_url}business/schedule/${date}?draft=true`
   const res = await fetch(url)
   if (!res.ok) {
     throw new Error('Network response was not ok')